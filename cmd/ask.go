@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/config"
+	"lamina/pkg/database"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask [question]",
+	Short: "Answer questions from your files with citations",
+	Long: `Answer a natural language question from the content of your indexed files.
+
+The best matching passages are retrieved with the vector search and handed to the
+provider's generation model, which streams back an answer citing each source as [n].
+
+Examples:
+  lamina ask "when is the lease up on the flat?"
+  lamina ask "what did we decide about the database migration?"`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		question := strings.Join(args, " ")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		// Unrelated passages still score somewhat, so ask filters harder than search
+		minScore := config.GetAskMinScore()
+		if cmd.Flags().Changed("min-score") {
+			minScore, _ = cmd.Flags().GetFloat64("min-score")
+		}
+
+		provider, err := ai.NewProvider(ctx)
+		if err != nil {
+			fmt.Printf("❌ Provider error: %v\n", err)
+			return
+		}
+
+		results, err := database.AdvancedSearchFiles(ctx, ai.Given(provider), &database.SearchParams{
+			SemanticQuery: question,
+			Limit:         limit,
+			MinScore:      minScore,
+		})
+		if err != nil {
+			fmt.Printf("❌ Search error: %v\n", err)
+			return
+		}
+
+		var sources []ai.Source
+		for _, result := range results {
+			if result.Chunk == nil {
+				continue
+			}
+			sources = append(sources, ai.Source{
+				Path:     result.File.Path,
+				Location: formatChunkLocation(result.Chunk),
+				Text:     result.Chunk.Text,
+			})
+		}
+
+		if len(sources) == 0 {
+			fmt.Println(ai.NoAnswer)
+			return
+		}
+
+		fmt.Println()
+		err = ai.AnswerQuestion(ctx, provider, question, sources, func(text string) error {
+			fmt.Print(text)
+			return nil
+		})
+		fmt.Println()
+
+		if errors.Is(err, ai.ErrUnsupported) {
+			fmt.Printf("⚠️ The %s provider can't generate answers; these files look relevant:\n", provider.Name())
+		} else if err != nil {
+			fmt.Printf("❌ Answer error: %v\n", err)
+			return
+		}
+
+		fmt.Println("\n📚 Sources:")
+		for i, source := range sources {
+			fmt.Printf("   [%d] %s (%s)\n", i+1, source.Path, source.Location)
+		}
+	},
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"lamina/pkg/database"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Embedding cache management",
+	Long:  `Inspect and prune the cache of embeddings keyed by file content hash and model`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show embedding cache size and hit rate",
+	Run: func(cmd *cobra.Command, args []string) {
+		stats, err := database.GetCacheStatistics()
+		if err != nil {
+			fmt.Printf("❌ Cache error: %v\n", err)
+			return
+		}
+
+		fmt.Printf("🗃️  Entries: %d (%s)\n", stats.Entries, formatFileSize(stats.Bytes))
+
+		models := make([]string, 0, len(stats.Models))
+		for model := range stats.Models {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+		for _, model := range models {
+			fmt.Printf("   🧠 %s: %d\n", model, stats.Models[model])
+		}
+
+		lookups := stats.Hits + stats.Misses
+		hitRate := 0.0
+		if lookups > 0 {
+			hitRate = float64(stats.Hits) / float64(lookups) * 100
+		}
+		fmt.Printf("🎯 Hits: %d, Misses: %d (%.1f%% hit rate)\n", stats.Hits, stats.Misses, hitRate)
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached embeddings no indexed file needs",
+	Long: `Remove cached embeddings whose content no indexed file has anymore and that
+weren't used within --max-age. Recently removed content is kept so restoring a
+backup or undoing a delete doesn't need new embeddings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		maxAge, _ := cmd.Flags().GetDuration("max-age")
+		otherModels, _ := cmd.Flags().GetBool("other-models")
+		resetStats, _ := cmd.Flags().GetBool("reset-stats")
+
+		activeModel, _, err := database.IndexedModel()
+		if err != nil {
+			fmt.Printf("❌ Cache error: %v\n", err)
+			return
+		}
+
+		removed, err := database.PruneCache(maxAge, activeModel, otherModels)
+		if err != nil {
+			fmt.Printf("❌ Cache error: %v\n", err)
+			return
+		}
+
+		if resetStats {
+			if err := database.ResetCacheStatistics(); err != nil {
+				fmt.Printf("❌ Cache error: %v\n", err)
+				return
+			}
+		}
+
+		fmt.Printf("🧹 Removed %d cached embeddings\n", removed)
+	},
+}
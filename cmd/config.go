@@ -10,6 +10,8 @@ var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Configuration management",
 	Long:  `Manage Lamina configuration settings`,
+	// Works without the index, so settings it doesn't fit can be restored
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
 }
 
 var pathCmd = &cobra.Command{
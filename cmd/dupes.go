@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"lamina/pkg/database"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var dupesCmd = &cobra.Command{
+	Use:   "dupes",
+	Short: "Find duplicate and near-duplicate files",
+	Long: `Find indexed files with identical content, and files whose content is nearly
+identical judging by their embeddings, grouped into clusters.
+
+Examples:
+  lamina dupes
+  lamina dupes --min-score 0.98
+  lamina dupes --exact --json | jq -r '.[].files[1:][].path'`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		minScore, _ := cmd.Flags().GetFloat64("min-score")
+		if exact, _ := cmd.Flags().GetBool("exact"); exact {
+			// No similarity reaches this, leaving only identical content
+			minScore = 2
+		}
+
+		clusters, err := database.FindDuplicates(minScore)
+		if err != nil {
+			fmt.Printf("❌ Duplicate search error: %v\n", err)
+			return
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if clusters == nil {
+				clusters = []database.DuplicateCluster{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(clusters); err != nil {
+				fmt.Printf("❌ Encoding error: %v\n", err)
+			}
+			return
+		}
+
+		if len(clusters) == 0 {
+			fmt.Println("No duplicates found")
+			return
+		}
+
+		fmt.Printf("Found %d clusters:\n\n", len(clusters))
+		for i, cluster := range clusters {
+			var total int64
+			for _, file := range cluster.Files {
+				total += file.Size
+			}
+
+			if cluster.Kind == database.DuplicateExact {
+				fmt.Printf("%d. 🟰 %d identical files (%s)\n", i+1, len(cluster.Files), formatFileSize(total))
+			} else {
+				fmt.Printf("%d. ≈ %d similar files (%s, score ≥ %.3f)\n", i+1, len(cluster.Files), formatFileSize(total), cluster.Score)
+			}
+			for _, file := range cluster.Files {
+				fmt.Printf("   %s  %8s  %s\n", file.ModTime.Format("2006-01-02 15:04"), formatFileSize(file.Size), file.Path)
+			}
+			fmt.Println()
+		}
+	},
+}
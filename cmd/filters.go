@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"lamina/pkg/config"
+	"lamina/pkg/database"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// addFilterFlags adds flags for the metadata filters of database.SearchParams.
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("type", nil, "only files with these extensions, e.g. pdf,docx")
+	cmd.Flags().String("after", "", "only files modified on or after this date (YYYY-MM-DD)")
+	cmd.Flags().String("before", "", "only files modified before this date (YYYY-MM-DD)")
+	cmd.Flags().String("min-size", "", "only files at least this large, e.g. 100KB")
+	cmd.Flags().String("max-size", "", "only files at most this large, e.g. 5MB")
+	cmd.Flags().StringSlice("path", nil, "only files whose path contains all of these")
+	cmd.Flags().IntP("limit", "n", 10, "number of files to show")
+	cmd.Flags().Float64("min-score", 0, "hide results scoring below this, 0-1 (default min_score from config)")
+}
+
+// filterParams builds search parameters from the flags added by addFilterFlags.
+func filterParams(cmd *cobra.Command) (*database.SearchParams, error) {
+	params := &database.SearchParams{MinScore: config.GetMinScore()}
+	params.Limit, _ = cmd.Flags().GetInt("limit")
+	if cmd.Flags().Changed("min-score") {
+		params.MinScore, _ = cmd.Flags().GetFloat64("min-score")
+	}
+
+	types, _ := cmd.Flags().GetStringSlice("type")
+	for _, fileType := range types {
+		params.FileTypes = append(params.FileTypes, strings.TrimPrefix(strings.ToLower(fileType), "."))
+	}
+	params.PathContains, _ = cmd.Flags().GetStringSlice("path")
+
+	for flag, target := range map[string]**time.Time{"after": &params.ModifiedAfter, "before": &params.ModifiedBefore} {
+		value, _ := cmd.Flags().GetString(flag)
+		if value == "" {
+			continue
+		}
+		date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s date %q (use YYYY-MM-DD)", flag, value)
+		}
+		*target = &date
+	}
+
+	for flag, target := range map[string]**int64{"min-size": &params.SizeMin, "max-size": &params.SizeMax} {
+		value, _ := cmd.Flags().GetString(flag)
+		if value == "" {
+			continue
+		}
+		size, err := database.ParseSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flag, err)
+		}
+		*target = &size
+	}
+	return params, nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/config"
+	"lamina/pkg/database"
+	"lamina/pkg/indexer"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Re-embed all indexed files with a new embedding model",
+	Long: `Re-embed every indexed chunk into a shadow vector table using the stored file contents.
+
+Searches keep using the current vectors until the new table is complete, then it is
+swapped in atomically and the configuration is updated to the new model.
+Restart a running daemon afterwards so it indexes with the new model too.
+
+Examples:
+  lamina reindex --model text-embedding-3-small --dimensions 1536
+  lamina reindex --provider ollama --model nomic-embed-text --dimensions 768`,
+	Args: cobra.NoArgs,
+	// The active vector table may not fit the new settings, it gets replaced
+	PersistentPreRunE: openStorage(database.OpenStorage),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		providerName, _ := cmd.Flags().GetString("provider")
+		model, _ := cmd.Flags().GetString("model")
+		dimensions, _ := cmd.Flags().GetInt("dimensions")
+
+		if providerName == "" {
+			providerName = config.GetProvider()
+		}
+		providerName = strings.ToLower(providerName)
+
+		// Override the config in-process; it is only persisted once the swap succeeds
+		changes := map[string]any{"provider": providerName}
+		if model != "" {
+			modelKey := providerName + "_embedding_model"
+			if !config.IsValidKey(modelKey) {
+				fmt.Printf("❌ Provider %s has no configurable embedding model\n", providerName)
+				return
+			}
+			changes[modelKey] = model
+		}
+		if dimensions > 0 {
+			dimensionsKey := providerName + "_embedding_dimensions"
+			if !config.IsValidKey(dimensionsKey) {
+				dimensionsKey = "embedding_dimensions"
+			}
+			changes[dimensionsKey] = dimensions
+		}
+		for key, value := range changes {
+			config.Set(key, value)
+		}
+
+		provider, err := ai.NewProviderByName(ctx, providerName)
+		if err != nil {
+			fmt.Printf("❌ Provider error: %v\n", err)
+			return
+		}
+
+		fmt.Printf("🔁 Reindexing with %s (%d dims)...\n", provider.EmbeddingModel(), provider.Dimensions())
+
+		err = indexer.Reindex(ctx, provider, func(done, total int) {
+			fmt.Printf("\r   📦 Embedded %d/%d chunks", done, total)
+		})
+		fmt.Println()
+		if err != nil {
+			fmt.Printf("❌ Reindex error: %v\n", err)
+			return
+		}
+
+		if err := config.Persist(changes); err != nil {
+			fmt.Printf("⚠️ Reindex finished but config could not be updated: %v\n", err)
+			return
+		}
+
+		fmt.Printf("✅ Now searching with %s\n", provider.EmbeddingModel())
+	},
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"lamina/pkg/database"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -12,18 +14,67 @@ func init() {
 	configCmd.AddCommand(pathCmd)
 	rootCmd.AddCommand(configCmd)
 
+	searchCmd.Flags().BoolP("verbose", "v", false, "show parsed parameters and content previews")
+	searchCmd.Flags().String("mode", "hybrid", "ranking: hybrid, semantic (vectors only) or lexical (keywords only)")
+	searchCmd.Flags().Float64("min-score", 0, "hide results scoring below this, 0-1 (default min_score from config)")
+	searchCmd.Flags().String("parser", "", "how to understand the query: llm, rules (offline) or rules-then-llm (default query_parser from config)")
 	rootCmd.AddCommand(searchCmd)
+
+	reindexCmd.Flags().String("model", "", "embedding model to switch to")
+	reindexCmd.Flags().String("provider", "", "provider to switch to (defaults to the configured one)")
+	reindexCmd.Flags().Int("dimensions", 0, "embedding dimensions of the new model")
+	rootCmd.AddCommand(reindexCmd)
+
+	askCmd.Flags().IntP("limit", "n", 5, "number of files to answer from")
+	askCmd.Flags().Float64("min-score", 0, "ignore passages scoring below this, 0-1 (default ask_min_score from config)")
+	rootCmd.AddCommand(askCmd)
+
+	addFilterFlags(similarCmd)
+	similarCmd.Flags().BoolP("verbose", "v", false, "show the passage of each file that matched best")
+	rootCmd.AddCommand(similarCmd)
+
+	dupesCmd.Flags().Float64("min-score", 0.95, "similarity from which files count as near-duplicates, 0-1")
+	dupesCmd.Flags().Bool("exact", false, "only report files with identical content")
+	dupesCmd.Flags().Bool("json", false, "print clusters as JSON")
+	rootCmd.AddCommand(dupesCmd)
+
+	topicsCmd.Flags().IntP("count", "k", 0, "number of topics (default chosen from the number of files)")
+	topicsCmd.Flags().Int("files", 5, "files to list per topic")
+	topicsCmd.Flags().Int64("seed", 1, "seed for the clustering, change it for a different grouping")
+	rootCmd.AddCommand(topicsCmd)
+
+	cachePruneCmd.Flags().Duration("max-age", 30*24*time.Hour, "keep unreferenced entries used more recently than this")
+	cachePruneCmd.Flags().Bool("other-models", false, "also remove entries of models other than the indexed one")
+	cachePruneCmd.Flags().Bool("reset-stats", false, "reset the hit and miss counters")
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
 }
 
 var rootCmd = &cobra.Command{
 	Use:   "lamina",
 	Short: "Lamina - semantic file assistant",
 	Long:  `Lamina is a local semantic assistant that helps you search and manage your files with natural language.`,
+	// Commands open the index before running, subcommands may override this
+	PersistentPreRunE: openStorage(database.NewStorage),
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Welcome to Lamina 🧠 — try `lamina --help`")
 	},
 }
 
+// openStorage returns a pre-run hook opening the index with open.
+func openStorage(open func() error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := open(); err != nil {
+			// Not a usage mistake, and main reports it
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return fmt.Errorf("failed to open the index: %w", err)
+		}
+		return nil
+	}
+}
+
 func Execute() error {
 	return rootCmd.Execute()
 }
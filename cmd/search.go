@@ -3,7 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/config"
 	"lamina/pkg/database"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -18,7 +21,20 @@ Examples:
   lamina search "all files about machine learning"
   lamina search "PDFs about geospatial indexing modified last week"  
   lamina search "Go code files dealing with databases from this month"
-  lamina search "documents containing 'API documentation' larger than 1MB"`,
+  lamina search "documents containing 'API documentation' larger than 1MB"
+  lamina search --mode lexical "INV-20391"
+  lamina search --min-score 0.6 "quarterly budget"
+  lamina search --parser rules "pdfs larger than 1MB modified last week"
+  lamina search 'type:pdf,docx modified:>2025-01-01 size:<5MB path:work -path:archive "exact phrase"'
+
+Inline filters (no model involved, for reproducible queries):
+  type:pdf,docx          file extensions
+  modified:>2025-01-01   also >=, <, <=, a..b, a day, month (2025-03) or year;
+                         ages count back from now, modified:>7d is the last 7 days
+  size:<5MB              also >, >=, <=, a..b
+  path:work -path:x      path contains / doesn't contain
+  limit:20               number of results
+  "exact phrase"         content must contain the phrase`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
@@ -27,13 +43,27 @@ Examples:
 
 		fmt.Printf("🔍 Parsing query: %s\n", query)
 
+		// Inline filters, the rules parser and lexical searches need no model
+		provider := ai.LazyProvider(ctx)
+
+		parser := config.GetQueryParser()
+		if cmd.Flags().Changed("parser") {
+			parser, _ = cmd.Flags().GetString("parser")
+		}
+
 		// Parse the natural language query
-		params, err := database.ParseQuery(ctx, query)
+		params, err := database.ParseQuery(ctx, provider, query, parser)
 		if err != nil {
 			fmt.Printf("❌ Query parsing error: %v\n", err)
 			return
 		}
 
+		params.Mode, _ = cmd.Flags().GetString("mode")
+		params.MinScore = config.GetMinScore()
+		if cmd.Flags().Changed("min-score") {
+			params.MinScore, _ = cmd.Flags().GetFloat64("min-score")
+		}
+
 		fmt.Println(params)
 
 		// Show what we parsed (optional debug info)
@@ -42,37 +72,55 @@ Examples:
 		}
 
 		// Search files
-		files, err := database.AdvancedSearchFiles(ctx, params)
+		results, err := database.AdvancedSearchFiles(ctx, provider, params)
 		if err != nil {
 			fmt.Printf("❌ Search error: %v\n", err)
 			return
 		}
 
-		if len(files) == 0 {
+		if len(results) == 0 {
 			fmt.Println("No files found matching your query")
 			return
 		}
 
-		fmt.Printf("Found %d files:\n\n", len(files))
-		for i, file := range files {
-			fmt.Printf("%d. %s\n", i+1, file.Path)
-			fmt.Printf("   📅 Modified: %s\n", file.ModTime.Format("2006-01-02 15:04"))
-			fmt.Printf("   📊 Size: %s\n", formatFileSize(file.Size))
-			if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
-				// Show content preview
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		printResults(results, params.SemanticQuery, params.SemanticQuery != "", verbose)
+	},
+}
+
+// ==============================================
+
+// printResults lists results with their scores when scored, and in verbose
+// mode the passage that matched query.
+func printResults(results []database.SearchResult, query string, scored, verbose bool) {
+	fmt.Printf("Found %d files:\n\n", len(results))
+	for i, result := range results {
+		file := result.File
+		fmt.Printf("%d. %s\n", i+1, file.Path)
+		fmt.Printf("   📅 Modified: %s\n", file.ModTime.Format("2006-01-02 15:04"))
+		fmt.Printf("   📊 Size: %s\n", formatFileSize(file.Size))
+		if scored {
+			fmt.Printf("   🎯 Score: %.3f\n", result.Score)
+		}
+		if verbose {
+			if result.Chunk != nil {
+				// Show the passage that matched, with the query terms highlighted
+				snippet := database.BestSnippet(result.Chunk, query)
+				fmt.Printf("   📍 Location: %s\n", formatSnippetLocation(snippet))
+				fmt.Printf("   📝 Match: %s\n", highlight(snippet.Text, snippet.Matches))
+			} else {
+				// Metadata-only searches have no match, show the start of the file
 				preview := strings.ReplaceAll(file.Content, "\n", " ")
 				if len(preview) > 200 {
 					preview = preview[:200] + "..."
 				}
 				fmt.Printf("   📝 Preview: %s\n", preview)
 			}
-			fmt.Println()
 		}
-	},
+		fmt.Println()
+	}
 }
 
-// ==============================================
-
 func printParsedParams(params *database.SearchParams) {
 	fmt.Println("🧠 Parsed search parameters:")
 	if params.SemanticQuery != "" {
@@ -90,6 +138,12 @@ func printParsedParams(params *database.SearchParams) {
 	if len(params.PathContains) > 0 {
 		fmt.Printf("   🗂️  Path contains: %v\n", params.PathContains)
 	}
+	if len(params.PathExcludes) > 0 {
+		fmt.Printf("   🚫 Path excludes: %v\n", params.PathExcludes)
+	}
+	if len(params.Phrases) > 0 {
+		fmt.Printf("   💬 Exact phrases: %q\n", params.Phrases)
+	}
 	if params.SizeMin != nil || params.SizeMax != nil {
 		fmt.Printf("   📊 Size range: %s\n", formatSizeRange(params.SizeMin, params.SizeMax))
 	}
@@ -110,6 +164,51 @@ func formatFileSize(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+func formatChunkLocation(chunk *database.Chunk) string {
+	location := fmt.Sprintf("lines %d-%d", chunk.StartLine, chunk.EndLine)
+	if chunk.StartLine == chunk.EndLine {
+		location = fmt.Sprintf("line %d", chunk.StartLine)
+	}
+	if chunk.Page > 0 {
+		location = fmt.Sprintf("page %d, %s", chunk.Page, location)
+	}
+	return location
+}
+
+func formatSnippetLocation(snippet database.Snippet) string {
+	location := fmt.Sprintf("line %d", snippet.Line)
+	if snippet.Page > 0 {
+		location = fmt.Sprintf("page %d, %s", snippet.Page, location)
+	}
+	return location
+}
+
+// highlight emphasises the matches within text when writing to a terminal.
+func highlight(text string, matches [][2]int) string {
+	if !colorOutput() {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		b.WriteString(text[last:match[0]])
+		b.WriteString("\033[1;33m" + text[match[0]:match[1]] + "\033[0m")
+		last = match[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// colorOutput reports whether stdout is a terminal that wants colors.
+func colorOutput() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func formatSizeRange(min, max *int64) string {
 	if min != nil && max != nil {
 		return fmt.Sprintf("%s - %s", formatFileSize(*min), formatFileSize(*max))
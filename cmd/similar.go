@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/database"
+	"lamina/pkg/indexer"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var similarCmd = &cobra.Command{
+	Use:   "similar [file]",
+	Short: "Find files similar to a file",
+	Long: `Find the indexed files most similar in content to a file.
+
+Indexed files are compared using their stored vectors. Files that aren't indexed
+are embedded on the fly, without adding them to the index.
+
+Examples:
+  lamina similar ~/Documents/report.pdf
+  lamina similar notes.md --type md,txt --after 2025-01-01 -n 5`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			fmt.Printf("❌ Invalid path: %v\n", err)
+			return
+		}
+
+		params, err := filterParams(cmd)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+
+		var file database.File
+		if err := database.Store.Where("path = ?", path).Limit(1).Find(&file).Error; err != nil {
+			fmt.Printf("❌ Lookup error: %v\n", err)
+			return
+		}
+
+		var vector []float32
+		if file.ID != 0 {
+			if vector, err = database.FileVector(file.ID); err != nil {
+				fmt.Printf("❌ Lookup error: %v\n", err)
+				return
+			}
+		}
+		if vector == nil {
+			// Not indexed (yet), embed it like the indexer would
+			fmt.Printf("🧮 Embedding %s\n", path)
+			provider, err := ai.NewProvider(ctx)
+			if err != nil {
+				fmt.Printf("❌ Provider error: %v\n", err)
+				return
+			}
+			if vector, err = indexer.EmbedFile(ctx, provider, path); err != nil {
+				fmt.Printf("❌ Embedding error: %v\n", err)
+				return
+			}
+		}
+
+		results, err := database.SimilarFiles(vector, params, file.ID)
+		if err != nil {
+			fmt.Printf("❌ Search error: %v\n", err)
+			return
+		}
+
+		if len(results) == 0 {
+			fmt.Println("No similar files found")
+			return
+		}
+
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		printResults(results, "", true, verbose)
+	},
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/database"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// topicSampleFiles is how many of the most central files are shown to the model for labelling.
+const topicSampleFiles = 5
+
+var topicsCmd = &cobra.Command{
+	Use:   "topics",
+	Short: "Group indexed files into topics",
+	Long: `Cluster the indexed files by the similarity of their content and list the files
+of each topic, most typical first.
+
+Topics are named by the provider's generation model. Providers that can't generate
+(e.g. local) name them by their most characteristic words instead.
+
+Examples:
+  lamina topics
+  lamina topics -k 8 --files 10`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		k, _ := cmd.Flags().GetInt("count")
+		seed, _ := cmd.Flags().GetInt64("seed")
+		showFiles, _ := cmd.Flags().GetInt("files")
+
+		topics, err := database.ClusterTopics(k, seed)
+		if err != nil {
+			fmt.Printf("❌ Clustering error: %v\n", err)
+			return
+		}
+
+		if len(topics) == 0 {
+			fmt.Println("No indexed files to cluster")
+			return
+		}
+
+		provider, err := ai.NewProvider(ctx)
+		if err != nil {
+			fmt.Printf("⚠️ Provider error, naming topics by their words: %v\n", err)
+		}
+
+		fmt.Printf("Found %d topics:\n\n", len(topics))
+		for i, topic := range topics {
+			label, named := strings.Join(topic.Terms, ", "), false
+			if provider != nil {
+				var excerpts []string
+				for _, file := range topic.Files[:min(topicSampleFiles, len(topic.Files))] {
+					excerpts = append(excerpts, file.Excerpt)
+				}
+
+				generated, err := ai.LabelTopic(ctx, provider, excerpts, topic.Terms)
+				if errors.Is(err, ai.ErrUnsupported) {
+					// Offline, stick to the words for every topic
+					provider = nil
+				} else if err != nil {
+					fmt.Printf("⚠️ Could not name topic %d: %v\n", i+1, err)
+				} else if generated != "" {
+					label, named = generated, true
+				}
+			}
+			if label == "" {
+				label = "(no distinctive words)"
+			}
+
+			fmt.Printf("%d. 🏷️  %s (%d files)\n", i+1, label, len(topic.Files))
+			if named && len(topic.Terms) > 0 {
+				fmt.Printf("   🔑 %s\n", strings.Join(topic.Terms, ", "))
+			}
+			for _, file := range topic.Files[:min(showFiles, len(topic.Files))] {
+				fmt.Printf("   %.3f  %s\n", file.Score, file.Path)
+			}
+			if hidden := len(topic.Files) - showFiles; hidden > 0 {
+				fmt.Printf("   … and %d more\n", hidden)
+			}
+			fmt.Println()
+		}
+	},
+}
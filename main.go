@@ -2,17 +2,15 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"lamina/cmd"
+	"lamina/pkg/ai"
 	"lamina/pkg/database"
 	"lamina/pkg/indexer"
 	"os"
 )
 
-func init() {
-	must("Initialize Database", database.NewStorage())
-}
-
 func must(action string, err error) {
 	if err != nil {
 		panic("-> Failed to " + action + ": " + err.Error())
@@ -34,15 +32,34 @@ func main() {
 }
 
 func runDaemon() {
+	must("Initialize Database", database.NewStorage())
+
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	prune := flags.Bool("prune", false, "remove indexed files the filetypes and ignore settings don't allow, even if they didn't change")
+	flags.Parse(os.Args[2:])
+
 	ctx := context.Background()
-	idx, err := indexer.NewIndexer()
+	provider, err := ai.NewProvider(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Daemon Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	idx, err := indexer.NewIndexer(provider)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Daemon Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *prune {
+		idx.ForcePrune()
+	}
+
 	fmt.Println("🚀 Lamina daemon starting...")
-	idx.Start(ctx)
+	if err := idx.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Daemon Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Keep daemon running
 	select {}
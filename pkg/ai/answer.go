@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NoAnswer is what the model is told to reply when the sources don't answer the question.
+const NoAnswer = "I couldn't find anything relevant to that in your indexed files."
+
+// Source is a retrieved passage the answer may cite.
+type Source struct {
+	Path     string
+	Location string
+	Text     string
+}
+
+// AnswerQuestion streams an answer to question grounded in sources, citing
+// them by their 1-based number in square brackets.
+func AnswerQuestion(ctx context.Context, provider Provider, question string, sources []Source, onText func(text string) error) error {
+	var excerpts strings.Builder
+	for i, source := range sources {
+		fmt.Fprintf(&excerpts, "[%d] %s (%s)\n%s\n\n", i+1, source.Path, source.Location, strings.TrimSpace(source.Text))
+	}
+
+	prompt := fmt.Sprintf(`Answer the question using only the numbered sources below, which are excerpts from the user's own files.
+
+	Rules:
+	- After every claim, cite the source(s) it comes from as [n], e.g. "The deadline moved to May [2]."
+	- Never state anything the sources don't support, and never cite a source that doesn't support the claim.
+	- If the sources don't answer the question, reply exactly: %s
+	- Be concise.
+
+	Sources:
+
+%s
+	Question: %s`, NoAnswer, excerpts.String(), question)
+
+	if err := provider.GenerateStream(ctx, prompt, onText); err != nil {
+		return fmt.Errorf("failed to generate answer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lamina/pkg/config"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+func init() {
+	Register("gemini", newGeminiProvider)
+}
+
+// geminiProvider talks to the Gemini API through a single shared client.
+type geminiProvider struct {
+	client         *genai.Client
+	embeddingModel string
+	chatModel      string
+	dimensions     int
+}
+
+func newGeminiProvider(ctx context.Context) (Provider, error) {
+	geminiKey := config.GetGeminiKey()
+	if geminiKey == "" {
+		return nil, errors.New("Gemini API key not found")
+	}
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:  geminiKey,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+
+	return &geminiProvider{
+		client:         client,
+		embeddingModel: config.GetGeminiEmbeddingModel(),
+		chatModel:      config.GetGeminiChatModel(),
+		dimensions:     config.GetEmbeddingDimensionsOf("gemini"),
+	}, nil
+}
+
+func (g *geminiProvider) Name() string {
+	return "gemini"
+}
+
+func (g *geminiProvider) EmbeddingModel() string {
+	return "gemini:" + g.embeddingModel
+}
+
+func (g *geminiProvider) Dimensions() int {
+	return g.dimensions
+}
+
+func (g *geminiProvider) EmbedDocuments(ctx context.Context, contents []string) ([][]float32, error) {
+	return g.embed(ctx, "RETRIEVAL_DOCUMENT", contents)
+}
+
+func (g *geminiProvider) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	embeddings, err := g.embed(ctx, "RETRIEVAL_QUERY", []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+	return embeddings[0], nil
+}
+
+// embed uses the same model for documents and queries; only the task type differs.
+func (g *geminiProvider) embed(ctx context.Context, taskType string, texts []string) ([][]float32, error) {
+	contents := make([]*genai.Content, len(texts))
+	for i, text := range texts {
+		contents[i] = genai.NewContentFromText(text, genai.RoleUser)
+	}
+
+	dimensions := int32(g.dimensions)
+	result, err := g.client.Models.EmbedContent(ctx,
+		g.embeddingModel,
+		contents,
+		&genai.EmbedContentConfig{
+			TaskType:             taskType,
+			OutputDimensionality: &dimensions,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings from API, got %d", len(texts), len(result.Embeddings))
+	}
+
+	vectors := make([][]float32, len(result.Embeddings))
+	for i, embedding := range result.Embeddings {
+		vectors[i] = embedding.Values
+	}
+	return vectors, checkDimensions(g, vectors)
+}
+
+func (g *geminiProvider) GenerateStructured(ctx context.Context, prompt string, schema *Schema) (string, error) {
+	result, err := g.client.Models.GenerateContent(
+		ctx,
+		g.chatModel,
+		genai.Text(prompt),
+		&genai.GenerateContentConfig{
+			ResponseMIMEType: "application/json",
+			ResponseSchema:   toGeminiSchema(schema),
+		},
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate structured output: %w", err)
+	}
+
+	return result.Text(), nil
+}
+
+func (g *geminiProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	result, err := g.client.Models.GenerateContent(ctx, g.chatModel, genai.Text(prompt), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate text: %w", err)
+	}
+
+	return result.Text(), nil
+}
+
+func (g *geminiProvider) GenerateStream(ctx context.Context, prompt string, onText func(text string) error) error {
+	for result, err := range g.client.Models.GenerateContentStream(ctx, g.chatModel, genai.Text(prompt), nil) {
+		if err != nil {
+			return fmt.Errorf("failed to generate text: %w", err)
+		}
+		if text := result.Text(); text != "" {
+			if err := onText(text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// toGeminiSchema converts a provider-neutral schema to the genai representation.
+func toGeminiSchema(schema *Schema) *genai.Schema {
+	if schema == nil {
+		return nil
+	}
+
+	converted := &genai.Schema{
+		Type:             genai.Type(strings.ToUpper(schema.Type)),
+		Description:      schema.Description,
+		Items:            toGeminiSchema(schema.Items),
+		Required:         schema.Required,
+		PropertyOrdering: schema.Ordering,
+	}
+
+	if len(schema.Properties) > 0 {
+		converted.Properties = make(map[string]*genai.Schema, len(schema.Properties))
+		for name, property := range schema.Properties {
+			converted.Properties[name] = toGeminiSchema(property)
+		}
+	}
+
+	return converted
+}
@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"context"
+	"hash/fnv"
+	"lamina/pkg/config"
+	"math"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	Register("local", newLocalProvider)
+}
+
+// localProvider embeds text in-process with the hashing trick: every unigram
+// and bigram is hashed into a fixed number of signed buckets, weighted by
+// sublinear term frequency and L2 normalised. It needs no network or API key,
+// which makes it usable on air-gapped machines and in CI. It can't generate
+// text, so queries are parsed by the rules parser instead of a model.
+type localProvider struct {
+	dimensions int
+}
+
+func newLocalProvider(ctx context.Context) (Provider, error) {
+	return &localProvider{dimensions: config.GetEmbeddingDimensionsOf("local")}, nil
+}
+
+func (l *localProvider) Name() string {
+	return "local"
+}
+
+func (l *localProvider) EmbeddingModel() string {
+	return "local:hashing-v1"
+}
+
+func (l *localProvider) Dimensions() int {
+	return l.dimensions
+}
+
+func (l *localProvider) EmbedDocuments(ctx context.Context, contents []string) ([][]float32, error) {
+	vectors := make([][]float32, len(contents))
+	for i, content := range contents {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = l.embed(content)
+	}
+	return vectors, nil
+}
+
+func (l *localProvider) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return l.embed(query), nil
+}
+
+func (l *localProvider) GenerateStructured(ctx context.Context, prompt string, schema *Schema) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (l *localProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (l *localProvider) GenerateStream(ctx context.Context, prompt string, onText func(text string) error) error {
+	return ErrUnsupported
+}
+
+func (l *localProvider) embed(text string) []float32 {
+	tokens := tokenize(text)
+
+	counts := make(map[string]int)
+	for i, token := range tokens {
+		counts[token]++
+		if i > 0 {
+			counts[tokens[i-1]+" "+token]++
+		}
+	}
+
+	vector := make([]float32, l.dimensions)
+	for feature, count := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+
+		bucket := sum % uint64(l.dimensions)
+		weight := float32(1 + math.Log(float64(count)))
+		// Use a high bit as the sign so collisions cancel out on average
+		if sum&(1<<63) != 0 {
+			weight = -weight
+		}
+		vector[bucket] += weight
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
+
+// tokenize lowercases text and splits it into alphanumeric words, dropping
+// very short tokens and common stop words.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := words[:0]
+	for _, word := range words {
+		if len(word) < 2 || stopWords[word] {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "has": true, "in": true,
+	"is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true,
+	"were": true, "will": true, "with": true, "all": true, "about": true,
+}
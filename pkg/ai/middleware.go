@@ -0,0 +1,319 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lamina/pkg/config"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// ErrCircuitOpen is returned without calling the provider while it is failing repeatedly.
+var ErrCircuitOpen = errors.New("provider circuit breaker is open")
+
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// resilientProvider retries transient failures with exponential backoff and
+// jitter, throttles requests and tokens per minute on the client side, and
+// stops calling a provider that keeps failing until it has had time to recover.
+type resilientProvider struct {
+	Provider
+
+	maxRetries int
+	requests   *rateLimiter
+	tokens     *rateLimiter
+	breaker    *circuitBreaker
+}
+
+// withResilience wraps provider using the limits configured for its name.
+func withResilience(provider Provider) Provider {
+	name := provider.Name()
+	return &resilientProvider{
+		Provider:   provider,
+		maxRetries: config.GetMaxRetries(),
+		requests:   newRateLimiter(config.GetRequestsPerMinute(name)),
+		tokens:     newRateLimiter(config.GetTokensPerMinute(name)),
+		breaker:    newCircuitBreaker(config.GetCircuitBreakerThreshold(), config.GetCircuitBreakerCooldown()),
+	}
+}
+
+func (r *resilientProvider) EmbedDocuments(ctx context.Context, contents []string) ([][]float32, error) {
+	var vectors [][]float32
+	err := r.call(ctx, estimateTokens(contents...), func() error {
+		var err error
+		vectors, err = r.Provider.EmbedDocuments(ctx, contents)
+		return err
+	})
+	return vectors, err
+}
+
+func (r *resilientProvider) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	var vector []float32
+	err := r.call(ctx, estimateTokens(query), func() error {
+		var err error
+		vector, err = r.Provider.EmbedQuery(ctx, query)
+		return err
+	})
+	return vector, err
+}
+
+func (r *resilientProvider) GenerateStructured(ctx context.Context, prompt string, schema *Schema) (string, error) {
+	var result string
+	err := r.call(ctx, estimateTokens(prompt), func() error {
+		var err error
+		result, err = r.Provider.GenerateStructured(ctx, prompt, schema)
+		return err
+	})
+	return result, err
+}
+
+func (r *resilientProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	var result string
+	err := r.call(ctx, estimateTokens(prompt), func() error {
+		var err error
+		result, err = r.Provider.Generate(ctx, prompt)
+		return err
+	})
+	return result, err
+}
+
+// GenerateStream is only retried while nothing has been streamed yet, since
+// text already handed to onText can't be taken back.
+func (r *resilientProvider) GenerateStream(ctx context.Context, prompt string, onText func(text string) error) error {
+	streamed := false
+	return r.call(ctx, estimateTokens(prompt), func() error {
+		err := r.Provider.GenerateStream(ctx, prompt, func(text string) error {
+			streamed = true
+			return onText(text)
+		})
+		if err != nil && streamed {
+			return &permanentError{err: err}
+		}
+		return err
+	})
+}
+
+// permanentError marks an error that must not be retried whatever its cause.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// call runs fn under the rate limits, retrying transient errors.
+func (r *resilientProvider) call(ctx context.Context, tokens int, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if !r.breaker.allow() {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, r.Name())
+		}
+
+		if err := r.requests.wait(ctx, 1); err != nil {
+			return err
+		}
+		if err := r.tokens.wait(ctx, float64(tokens)); err != nil {
+			return err
+		}
+
+		err := fn()
+		if err == nil || errors.Is(err, ErrUnsupported) {
+			r.breaker.success()
+			return err
+		}
+
+		if !isRetryable(err) {
+			// Permanent errors (bad key, bad request) still mean the provider is up
+			r.breaker.success()
+			return err
+		}
+
+		r.breaker.failure()
+		if attempt >= r.maxRetries {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		delay := backoff(attempt)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+			delay = statusErr.RetryAfter
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// backoff returns the exponential delay for attempt with equal jitter.
+func backoff(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// IsTransient reports whether err may go away when the call is made again
+// later: the provider was rate limited, failing or unreachable, or the
+// circuit breaker stopped calling it.
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || isRetryable(err)
+}
+
+// isRetryable reports whether err is a rate limit, server error or network failure.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.Code)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// estimateTokens approximates the token count of texts at four bytes per token.
+func estimateTokens(texts ...string) int {
+	total := 0
+	for _, text := range texts {
+		total += len(text)/4 + 1
+	}
+	return total
+}
+
+// rateLimiter is a token bucket refilled continuously to perMinute per minute.
+// A nil limiter never waits.
+type rateLimiter struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64 // per second
+	tokens   float64
+	last     time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		tokens:   float64(perMinute),
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until n tokens are available and takes them.
+func (l *rateLimiter) wait(ctx context.Context, n float64) error {
+	if l == nil {
+		return nil
+	}
+
+	// A single request larger than the bucket can only ever wait for a full one
+	n = min(n, l.capacity)
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = min(l.capacity, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+
+		if l.tokens >= n {
+			l.tokens -= n
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((n - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// circuitBreaker opens after threshold consecutive failures and lets a single
+// trial call through once cooldown has passed.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+
+	// Open: wait out the cooldown, then allow one half-open trial at a time
+	if time.Since(b.openedAt) < b.cooldown || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
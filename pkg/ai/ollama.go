@@ -0,0 +1,144 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lamina/pkg/config"
+	"strings"
+)
+
+func init() {
+	Register("ollama", newOllamaProvider)
+}
+
+// ollamaProvider talks to a local Ollama server so nothing leaves the machine.
+type ollamaProvider struct {
+	host           string
+	embeddingModel string
+	chatModel      string
+	dimensions     int
+	// requested is the size asked of the model, 0 for its own
+	requested int
+}
+
+type ollamaEmbedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+type ollamaGenerateRequest struct {
+	Model  string  `json:"model"`
+	Prompt string  `json:"prompt"`
+	Stream bool    `json:"stream"`
+	Format *Schema `json:"format,omitempty"`
+}
+
+type ollamaGenerateResponse struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+}
+
+func newOllamaProvider(ctx context.Context) (Provider, error) {
+	host := strings.TrimRight(config.GetOllamaHost(), "/")
+
+	// OLLAMA_HOST is commonly set without a scheme (e.g. 127.0.0.1:11434)
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	return &ollamaProvider{
+		host:           host,
+		embeddingModel: config.GetOllamaEmbeddingModel(),
+		chatModel:      config.GetOllamaChatModel(),
+		dimensions:     config.GetEmbeddingDimensionsOf("ollama"),
+		requested:      config.GetProviderEmbeddingDimensions("ollama"),
+	}, nil
+}
+
+func (o *ollamaProvider) Name() string {
+	return "ollama"
+}
+
+func (o *ollamaProvider) EmbeddingModel() string {
+	return "ollama:" + o.embeddingModel
+}
+
+func (o *ollamaProvider) Dimensions() int {
+	return o.dimensions
+}
+
+func (o *ollamaProvider) EmbedDocuments(ctx context.Context, contents []string) ([][]float32, error) {
+	var resp ollamaEmbedResponse
+	err := postJSON(ctx, "Ollama", o.host+"/api/embed", nil, ollamaEmbedRequest{
+		Model:      o.embeddingModel,
+		Input:      contents,
+		Dimensions: o.requested,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+
+	if len(resp.Embeddings) != len(contents) {
+		return nil, fmt.Errorf("expected %d embeddings from API, got %d", len(contents), len(resp.Embeddings))
+	}
+	return resp.Embeddings, checkDimensions(o, resp.Embeddings)
+}
+
+func (o *ollamaProvider) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	embeddings, err := o.EmbedDocuments(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+	return embeddings[0], nil
+}
+
+func (o *ollamaProvider) GenerateStructured(ctx context.Context, prompt string, schema *Schema) (string, error) {
+	return o.generate(ctx, prompt, schema)
+}
+
+func (o *ollamaProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return o.generate(ctx, prompt, nil)
+}
+
+func (o *ollamaProvider) generate(ctx context.Context, prompt string, format *Schema) (string, error) {
+	var resp ollamaGenerateResponse
+	err := postJSON(ctx, "Ollama", o.host+"/api/generate", nil, ollamaGenerateRequest{
+		Model:  o.chatModel,
+		Prompt: prompt,
+		Format: format,
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate text: %w", err)
+	}
+	return resp.Response, nil
+}
+
+func (o *ollamaProvider) GenerateStream(ctx context.Context, prompt string, onText func(text string) error) error {
+	request := ollamaGenerateRequest{
+		Model:  o.chatModel,
+		Prompt: prompt,
+		Stream: true,
+	}
+
+	// Newline delimited JSON objects, the last one has done set
+	err := postStream(ctx, "Ollama", o.host+"/api/generate", nil, request, func(line []byte) error {
+		var chunk ollamaGenerateResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("failed to decode Ollama stream: %w", err)
+		}
+		if chunk.Response == "" {
+			return nil
+		}
+		return onText(chunk.Response)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate text: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lamina/pkg/config"
+	"strings"
+)
+
+const openAIDefaultBaseURL = "https://api.openai.com/v1"
+
+func init() {
+	Register("openai", newOpenAIProvider)
+}
+
+// openAIProvider talks to the OpenAI API or any endpoint speaking the same
+// protocol (llama.cpp server, vLLM, LM Studio, ...).
+type openAIProvider struct {
+	baseURL        string
+	apiKey         string
+	embeddingModel string
+	chatModel      string
+	dimensions     int
+	// requested is the size asked of the model, 0 for its own
+	requested int
+}
+
+type openAIEmbeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string  `json:"name"`
+	Schema *Schema `json:"schema"`
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta openAIMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+}
+
+func newOpenAIProvider(ctx context.Context) (Provider, error) {
+	baseURL := strings.TrimRight(config.GetOpenAIBaseURL(), "/")
+	apiKey := config.GetOpenAIKey()
+
+	// Self-hosted compatible servers usually don't need a key
+	if apiKey == "" && baseURL == openAIDefaultBaseURL {
+		return nil, errors.New("OpenAI API key not found")
+	}
+
+	return &openAIProvider{
+		baseURL:        baseURL,
+		apiKey:         apiKey,
+		embeddingModel: config.GetOpenAIEmbeddingModel(),
+		chatModel:      config.GetOpenAIChatModel(),
+		dimensions:     config.GetEmbeddingDimensionsOf("openai"),
+		requested:      config.GetProviderEmbeddingDimensions("openai"),
+	}, nil
+}
+
+func (o *openAIProvider) Name() string {
+	return "openai"
+}
+
+func (o *openAIProvider) EmbeddingModel() string {
+	return "openai:" + o.embeddingModel
+}
+
+func (o *openAIProvider) Dimensions() int {
+	return o.dimensions
+}
+
+func (o *openAIProvider) headers() map[string]string {
+	if o.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + o.apiKey}
+}
+
+func (o *openAIProvider) EmbedDocuments(ctx context.Context, contents []string) ([][]float32, error) {
+	var resp openAIEmbeddingResponse
+	err := postJSON(ctx, "OpenAI", o.baseURL+"/embeddings", o.headers(), openAIEmbeddingRequest{
+		Model:      o.embeddingModel,
+		Input:      contents,
+		Dimensions: o.requested,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+
+	if len(resp.Data) != len(contents) {
+		return nil, fmt.Errorf("expected %d embeddings from API, got %d", len(contents), len(resp.Data))
+	}
+
+	// Entries carry their input index and aren't guaranteed to be in order
+	vectors := make([][]float32, len(contents))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, checkDimensions(o, vectors)
+}
+
+func (o *openAIProvider) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	embeddings, err := o.EmbedDocuments(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+	return embeddings[0], nil
+}
+
+func (o *openAIProvider) GenerateStructured(ctx context.Context, prompt string, schema *Schema) (string, error) {
+	return o.chat(ctx, prompt, &openAIResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &openAIJSONSchema{Name: "response", Schema: schema},
+	})
+}
+
+func (o *openAIProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return o.chat(ctx, prompt, nil)
+}
+
+func (o *openAIProvider) chat(ctx context.Context, prompt string, format *openAIResponseFormat) (string, error) {
+	var resp openAIChatResponse
+	err := postJSON(ctx, "OpenAI", o.baseURL+"/chat/completions", o.headers(), openAIChatRequest{
+		Model:          o.chatModel,
+		Messages:       []openAIMessage{{Role: "user", Content: prompt}},
+		ResponseFormat: format,
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate text: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", errors.New("no choices returned from API")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+func (o *openAIProvider) GenerateStream(ctx context.Context, prompt string, onText func(text string) error) error {
+	request := openAIChatRequest{
+		Model:    o.chatModel,
+		Messages: []openAIMessage{{Role: "user", Content: prompt}},
+		Stream:   true,
+	}
+
+	// Server-sent events: "data: {...}" lines terminated by "data: [DONE]"
+	err := postStream(ctx, "OpenAI", o.baseURL+"/chat/completions", o.headers(), request, func(line []byte) error {
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			return nil
+		}
+		data = bytes.TrimSpace(data)
+		if string(data) == "[DONE]" {
+			return nil
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to decode OpenAI stream: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		return onText(chunk.Choices[0].Delta.Content)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate text: %w", err)
+	}
+	return nil
+}
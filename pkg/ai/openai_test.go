@@ -0,0 +1,337 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lamina/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// setConfig overrides a configuration key for the duration of the test.
+func setConfig(t *testing.T, key string, value any) {
+	t.Helper()
+	previous := viper.Get(key)
+	config.Set(key, value)
+	t.Cleanup(func() { config.Set(key, previous) })
+}
+
+// newTestOpenAI returns an OpenAI provider talking to handler at baseURL+path.
+func newTestOpenAI(t *testing.T, path string, handler http.HandlerFunc) *openAIProvider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	setConfig(t, "openai_base_url", server.URL+path)
+	setConfig(t, "openai_key", "test-key")
+	setConfig(t, "openai_embedding_model", "test-embedding")
+	setConfig(t, "openai_chat_model", "gpt-4o-mini")
+	setConfig(t, "openai_embedding_dimensions", 0)
+	setConfig(t, "embedding_dimensions", 3)
+
+	provider, err := newOpenAIProvider(context.Background())
+	if err != nil {
+		t.Fatalf("newOpenAIProvider: %v", err)
+	}
+	return provider.(*openAIProvider)
+}
+
+// decodeBody decodes the JSON request body into a generic map.
+func decodeBody(t *testing.T, r *http.Request) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Errorf("decoding request body: %v", err)
+	}
+	return body
+}
+
+func TestOpenAIEmbedDocuments(t *testing.T) {
+	var body map[string]any
+	provider := newTestOpenAI(t, "/v1", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("path = %s, want /v1/embeddings", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		body = decodeBody(t, r)
+
+		// Out of order, as the API doesn't promise any
+		fmt.Fprint(w, `{"data": [
+			{"index": 1, "embedding": [4, 5, 6]},
+			{"index": 0, "embedding": [1, 2, 3]}
+		]}`)
+	})
+
+	vectors, err := provider.EmbedDocuments(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("EmbedDocuments: %v", err)
+	}
+
+	want := [][]float32{{1, 2, 3}, {4, 5, 6}}
+	if !reflect.DeepEqual(vectors, want) {
+		t.Errorf("vectors = %v, want %v", vectors, want)
+	}
+	if body["model"] != "test-embedding" {
+		t.Errorf("model = %v", body["model"])
+	}
+	if !reflect.DeepEqual(body["input"], []any{"first", "second"}) {
+		t.Errorf("input = %v", body["input"])
+	}
+	if _, ok := body["dimensions"]; ok {
+		t.Errorf("dimensions sent although openai_embedding_dimensions is unset: %v", body["dimensions"])
+	}
+}
+
+func TestOpenAIEmbedDocumentsRequestsConfiguredDimensions(t *testing.T) {
+	var body map[string]any
+	provider := newTestOpenAI(t, "", func(w http.ResponseWriter, r *http.Request) {
+		body = decodeBody(t, r)
+		fmt.Fprint(w, `{"data": [{"index": 0, "embedding": [1, 0]}]}`)
+	})
+	setConfig(t, "openai_embedding_dimensions", 2)
+	reconfigured, err := newOpenAIProvider(context.Background())
+	if err != nil {
+		t.Fatalf("newOpenAIProvider: %v", err)
+	}
+	provider = reconfigured.(*openAIProvider)
+
+	if provider.Dimensions() != 2 {
+		t.Errorf("Dimensions() = %d, want 2", provider.Dimensions())
+	}
+	if _, err := provider.EmbedQuery(context.Background(), "query"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+	if body["dimensions"] != float64(2) {
+		t.Errorf("dimensions = %v, want 2", body["dimensions"])
+	}
+}
+
+func TestOpenAIKnownModelDimensions(t *testing.T) {
+	setConfig(t, "openai_key", "test-key")
+	setConfig(t, "openai_embedding_dimensions", 0)
+	setConfig(t, "embedding_dimensions", 3)
+
+	for model, want := range map[string]int{
+		"text-embedding-3-large": 3072,
+		"text-embedding-3-small": 1536,
+		"custom-embedding":       3,
+	} {
+		setConfig(t, "openai_embedding_model", model)
+		provider, err := newOpenAIProvider(context.Background())
+		if err != nil {
+			t.Fatalf("newOpenAIProvider: %v", err)
+		}
+		if provider.Dimensions() != want {
+			t.Errorf("%s: Dimensions() = %d, want %d", model, provider.Dimensions(), want)
+		}
+	}
+}
+
+func TestOpenAIEmbedDocumentsChecksDimensions(t *testing.T) {
+	provider := newTestOpenAI(t, "", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": [{"index": 0, "embedding": [1, 2]}]}`)
+	})
+
+	_, err := provider.EmbedDocuments(context.Background(), []string{"text"})
+	if err == nil || !strings.Contains(err.Error(), "openai_embedding_dimensions") {
+		t.Errorf("err = %v, want a dimension mismatch naming openai_embedding_dimensions", err)
+	}
+}
+
+func TestOpenAIGenerateStructured(t *testing.T) {
+	schema := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"query": {Type: "string"}},
+		Required:   []string{"query"},
+	}
+
+	var body map[string]any
+	provider := newTestOpenAI(t, "/v1", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s, want /v1/chat/completions", r.URL.Path)
+		}
+		body = decodeBody(t, r)
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "{\"query\": \"taxes\"}"}}]}`)
+	})
+
+	text, err := provider.GenerateStructured(context.Background(), "find my taxes", schema)
+	if err != nil {
+		t.Fatalf("GenerateStructured: %v", err)
+	}
+	if text != `{"query": "taxes"}` {
+		t.Errorf("text = %q", text)
+	}
+
+	if body["model"] != "gpt-4o-mini" {
+		t.Errorf("model = %v", body["model"])
+	}
+	wantMessages := []any{map[string]any{"role": "user", "content": "find my taxes"}}
+	if !reflect.DeepEqual(body["messages"], wantMessages) {
+		t.Errorf("messages = %v", body["messages"])
+	}
+	wantFormat := map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name": "response",
+			"schema": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"query": map[string]any{"type": "string"}},
+				"required":   []any{"query"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(body["response_format"], wantFormat) {
+		t.Errorf("response_format = %v", body["response_format"])
+	}
+}
+
+func TestOpenAIGenerateOmitsResponseFormat(t *testing.T) {
+	var body map[string]any
+	provider := newTestOpenAI(t, "", func(w http.ResponseWriter, r *http.Request) {
+		body = decodeBody(t, r)
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "hello"}}]}`)
+	})
+
+	text, err := provider.Generate(context.Background(), "say hello")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if text != "hello" {
+		t.Errorf("text = %q", text)
+	}
+	if _, ok := body["response_format"]; ok {
+		t.Errorf("response_format sent for free-form text: %v", body["response_format"])
+	}
+}
+
+func TestOpenAICustomBaseURL(t *testing.T) {
+	var path, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, authorization = r.URL.Path, r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`)
+	}))
+	defer server.Close()
+
+	// Self-hosted servers work without a key, trailing slashes are dropped
+	setConfig(t, "openai_base_url", server.URL+"/llama/v1/")
+	setConfig(t, "openai_key", "")
+
+	provider, err := newOpenAIProvider(context.Background())
+	if err != nil {
+		t.Fatalf("newOpenAIProvider: %v", err)
+	}
+	if _, err := provider.Generate(context.Background(), "hi"); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if path != "/llama/v1/chat/completions" {
+		t.Errorf("path = %s, want /llama/v1/chat/completions", path)
+	}
+	if authorization != "" {
+		t.Errorf("Authorization = %q, want none without a key", authorization)
+	}
+}
+
+func TestOpenAIRequiresKeyForDefaultBaseURL(t *testing.T) {
+	setConfig(t, "openai_base_url", openAIDefaultBaseURL)
+	setConfig(t, "openai_key", "")
+
+	if _, err := newOpenAIProvider(context.Background()); err == nil {
+		t.Error("newOpenAIProvider succeeded without a key for the OpenAI API")
+	}
+}
+
+func TestOpenAIErrorStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		wantRetry  time.Duration
+	}{
+		{name: "bad request", status: http.StatusBadRequest},
+		{name: "unauthorized", status: http.StatusUnauthorized},
+		{name: "rate limited", status: http.StatusTooManyRequests, retryAfter: "7", wantRetry: 7 * time.Second},
+		{name: "server error", status: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newTestOpenAI(t, "", func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, `{"error": {"message": "nope"}}`)
+			})
+
+			calls := map[string]func() error{
+				"embed": func() error {
+					_, err := provider.EmbedDocuments(context.Background(), []string{"text"})
+					return err
+				},
+				"chat": func() error {
+					_, err := provider.Generate(context.Background(), "prompt")
+					return err
+				},
+				"stream": func() error {
+					return provider.GenerateStream(context.Background(), "prompt", func(string) error { return nil })
+				},
+			}
+			for call, run := range calls {
+				var statusErr *StatusError
+				if err := run(); !errors.As(err, &statusErr) {
+					t.Fatalf("%s: err = %v, want a StatusError", call, err)
+				}
+				if statusErr.Provider != "OpenAI" || statusErr.StatusCode != test.status {
+					t.Errorf("%s: got %s %d, want OpenAI %d", call, statusErr.Provider, statusErr.StatusCode, test.status)
+				}
+				if !strings.Contains(statusErr.Body, "nope") {
+					t.Errorf("%s: body = %q", call, statusErr.Body)
+				}
+				if statusErr.RetryAfter != test.wantRetry {
+					t.Errorf("%s: RetryAfter = %v, want %v", call, statusErr.RetryAfter, test.wantRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIGenerateStream(t *testing.T) {
+	var body map[string]any
+	provider := newTestOpenAI(t, "", func(w http.ResponseWriter, r *http.Request) {
+		body = decodeBody(t, r)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"role\": \"assistant\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"Hel\"}}]}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"lo\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	var text strings.Builder
+	err := provider.GenerateStream(context.Background(), "greet", func(piece string) error {
+		text.WriteString(piece)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateStream: %v", err)
+	}
+	if text.String() != "Hello" {
+		t.Errorf("text = %q, want Hello", text.String())
+	}
+	if body["stream"] != true {
+		t.Errorf("stream = %v, want true", body["stream"])
+	}
+}
@@ -0,0 +1,137 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lamina/pkg/config"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned by providers for operations they can't perform.
+var ErrUnsupported = errors.New("operation not supported by provider")
+
+// Provider is an embedding and text generation backend.
+type Provider interface {
+	// Name returns the registry name of the provider.
+	Name() string
+
+	// EmbeddingModel identifies the model behind the vectors, e.g. "gemini:gemini-embedding-001".
+	// Vectors from different models must never be compared.
+	EmbeddingModel() string
+
+	// Dimensions returns the length of the vectors the provider produces.
+	Dimensions() int
+
+	// EmbedDocuments embeds file contents for indexing, one vector per content.
+	EmbedDocuments(ctx context.Context, contents []string) ([][]float32, error)
+
+	// EmbedQuery embeds a search query.
+	EmbedQuery(ctx context.Context, query string) ([]float32, error)
+
+	// GenerateStructured generates JSON output matching schema.
+	GenerateStructured(ctx context.Context, prompt string, schema *Schema) (string, error)
+
+	// Generate generates free-form text.
+	Generate(ctx context.Context, prompt string) (string, error)
+
+	// GenerateStream generates free-form text, calling onText with each piece as it arrives.
+	GenerateStream(ctx context.Context, prompt string, onText func(text string) error) error
+}
+
+// Schema describes the JSON shape of a structured generation response.
+// Types use JSON Schema names (object, string, array, integer, ...).
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Ordering    []string           `json:"-"`
+}
+
+// checkDimensions verifies every vector has the number of dimensions provider is configured for.
+func checkDimensions(provider Provider, vectors [][]float32) error {
+	for _, vector := range vectors {
+		if len(vector) != provider.Dimensions() {
+			return fmt.Errorf("embedding has %d dimensions but %s is configured for %d; set %s_embedding_dimensions to match the model", len(vector), provider.Name(), provider.Dimensions(), provider.Name())
+		}
+	}
+	return nil
+}
+
+// Factory builds a Provider from the current configuration.
+type Factory func(ctx context.Context) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a provider available under name. It panics on duplicates.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name = strings.ToLower(name)
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("ai: provider %s registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Providers returns the names of all registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider builds the provider selected by the `provider` config value.
+func NewProvider(ctx context.Context) (Provider, error) {
+	return NewProviderByName(ctx, config.GetProvider())
+}
+
+// NewProviderByName builds the provider registered under name.
+func NewProviderByName(ctx context.Context, name string) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[strings.ToLower(name)]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("invalid provider %s is not supported (available: %s)", name, strings.Join(Providers(), ", "))
+	}
+
+	provider, err := factory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withResilience(provider), nil
+}
+
+// ProviderFunc returns a provider once one is needed.
+type ProviderFunc func() (Provider, error)
+
+// LazyProvider builds the configured provider on its first call only, for
+// commands that may get by without one. Later calls return the same provider
+// or error.
+func LazyProvider(ctx context.Context) ProviderFunc {
+	return sync.OnceValues(func() (Provider, error) {
+		return NewProvider(ctx)
+	})
+}
+
+// Given returns a ProviderFunc for a provider built already.
+func Given(provider Provider) ProviderFunc {
+	return func() (Provider, error) {
+		return provider, nil
+	}
+}
@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Terms splits text into lowercase words, leaving out stop words and single characters.
+func Terms(text string) []string {
+	return tokenize(text)
+}
+
+// LabelTopic names the topic shared by excerpts of files that were clustered
+// together, with terms as the words most characteristic of the cluster.
+func LabelTopic(ctx context.Context, provider Provider, excerpts []string, terms []string) (string, error) {
+	var documents strings.Builder
+	for i, excerpt := range excerpts {
+		fmt.Fprintf(&documents, "--- Document %d ---\n%s\n\n", i+1, strings.TrimSpace(excerpt))
+	}
+
+	prompt := fmt.Sprintf(`The documents below were grouped together because their content is similar.
+	Name the topic they share in 2 to 5 words, e.g. "Kubernetes deployment notes" or "Household bills".
+	Reply with the name only, without quotes or punctuation at the end.
+
+	Characteristic words: %s
+
+%s`, strings.Join(terms, ", "), documents.String())
+
+	label, err := provider.Generate(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to label topic: %w", err)
+	}
+
+	// Models like to decorate short answers anyway
+	label = strings.TrimSpace(label)
+	if line, _, found := strings.Cut(label, "\n"); found {
+		label = line
+	}
+	return strings.Trim(label, `"'*. `), nil
+}
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 func init() {
@@ -48,10 +51,32 @@ func init() {
 
 	// Set default values in case config file is missing some keys
 	viper.SetDefault("provider", "gemini")
+	viper.SetDefault("embedding_dimensions", 3072)
+	viper.SetDefault("gemini_embedding_model", "gemini-embedding-001")
+	viper.SetDefault("gemini_chat_model", "gemini-2.0-flash-exp")
+	viper.SetDefault("openai_base_url", "https://api.openai.com/v1")
+	viper.SetDefault("openai_embedding_model", "text-embedding-3-large")
+	viper.SetDefault("openai_chat_model", "gpt-4o-mini")
+	viper.SetDefault("ollama_host", "http://localhost:11434")
+	viper.SetDefault("ollama_embedding_model", "nomic-embed-text")
+	viper.SetDefault("ollama_chat_model", "llama3.2")
+	viper.SetDefault("embed_batch_size", 32)
+	viper.SetDefault("extract_workers", 0)
+	viper.SetDefault("embed_workers", 2)
+	viper.SetDefault("embed_flush_interval", "2s")
+	viper.SetDefault("chunk_size", 2000)
+	viper.SetDefault("chunk_overlap", 200)
+	viper.SetDefault("max_retries", 5)
+	viper.SetDefault("circuit_breaker_threshold", 5)
+	viper.SetDefault("circuit_breaker_cooldown", "30s")
+	viper.SetDefault("gemini_requests_per_minute", 100)
+	viper.SetDefault("min_score", 0.0)
+	viper.SetDefault("ask_min_score", 0.6)
+	viper.SetDefault("query_parser", "rules-then-llm")
 	viper.SetDefault("database_path", filepath.Join(configDir, "lamina.db"))
 	viper.SetDefault("watch_paths", []string{filepath.Join(home, "Documents")})
 	viper.SetDefault("ignore_patterns", []string{".git", "node_modules", "*.log"})
-	viper.SetDefault("filetypes", []string{".*\\.txt$", ".*\\.md$"})
+	viper.SetDefault("filetypes", []string{".*\\.txt$", ".*\\.md$", ".*\\.pdf$", ".*\\.docx$"})
 
 }
 
@@ -65,6 +90,75 @@ func Get(key string) string {
 	return result
 }
 
+// Set overrides a configuration value for the running process only.
+func Set(key string, value any) {
+	viper.Set(key, value)
+}
+
+// Persist writes scalar values into the config file and the running process.
+// The file is edited line by line, so its comments, key order and every other
+// key stay as they are.
+func Persist(values map[string]any) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("could not read config at %s: %w", configPath, err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	for key, value := range values {
+		var node yaml.Node
+		if err := node.Encode(value); err != nil || node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("could not persist %s: only scalar values can be written", key)
+		}
+		encoded, err := yaml.Marshal(&node)
+		if err != nil {
+			return fmt.Errorf("could not persist %s: %w", key, err)
+		}
+		lines = setConfigLine(lines, key, strings.TrimSuffix(string(encoded), "\n"))
+	}
+
+	if err := os.WriteFile(configPath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("could not write config at %s: %w", configPath, err)
+	}
+	for key, value := range values {
+		viper.Set(key, value)
+	}
+	return nil
+}
+
+// setConfigLine sets the top-level key of the YAML lines to value. It replaces
+// the line of the key, or else uncomments a commented out one like those of
+// defaultConfig, or else appends a line.
+func setConfigLine(lines []string, key, value string) []string {
+	line := key + ": " + value
+	commented := -1
+	for idx, existing := range lines {
+		if strings.HasPrefix(existing, key+":") {
+			lines[idx] = line
+			return lines
+		}
+		if commented < 0 && strings.HasPrefix(strings.TrimLeft(existing, "# "), key+":") {
+			commented = idx
+		}
+	}
+
+	if commented >= 0 {
+		lines[commented] = line
+		return lines
+	}
+	return append(lines, line)
+}
+
+// IsValidKey reports whether key is a known configuration key.
+func IsValidKey(key string) bool {
+	for _, valid := range totalConfigKeys {
+		if strings.EqualFold(valid, key) {
+			return true
+		}
+	}
+	return false
+}
+
 func GetStringSlice(key string) []string {
 	return viper.GetStringSlice(key)
 }
@@ -78,28 +172,178 @@ func GetOpenAIKey() string {
 	return Get("OPENAI_KEY")
 }
 
+// GetOpenAIBaseURL returns the base URL of the OpenAI compatible endpoint.
+func GetOpenAIBaseURL() string {
+	return Get("OPENAI_BASE_URL")
+}
+
+// GetOpenAIEmbeddingModel returns the OpenAI embedding model name.
+func GetOpenAIEmbeddingModel() string {
+	return Get("OPENAI_EMBEDDING_MODEL")
+}
+
+// GetOpenAIChatModel returns the OpenAI chat model name.
+func GetOpenAIChatModel() string {
+	return Get("OPENAI_CHAT_MODEL")
+}
+
 // GetGeminiKey returns the Gemini API key.
 func GetGeminiKey() string {
 	return Get("GEMINI_KEY")
 }
 
+// GetGeminiEmbeddingModel returns the Gemini embedding model name.
+func GetGeminiEmbeddingModel() string {
+	return Get("GEMINI_EMBEDDING_MODEL")
+}
+
+// GetGeminiChatModel returns the Gemini model used for query parsing and generation.
+func GetGeminiChatModel() string {
+	return Get("GEMINI_CHAT_MODEL")
+}
+
+// GetOllamaHost returns the Ollama server address.
+func GetOllamaHost() string {
+	return Get("OLLAMA_HOST")
+}
+
+// GetOllamaEmbeddingModel returns the Ollama embedding model name.
+func GetOllamaEmbeddingModel() string {
+	return Get("OLLAMA_EMBEDDING_MODEL")
+}
+
+// GetOllamaChatModel returns the Ollama model used for query parsing and generation.
+func GetOllamaChatModel() string {
+	return Get("OLLAMA_CHAT_MODEL")
+}
+
 // GetProvider returns the LLM provider.
 func GetProvider() string {
 	return Get("PROVIDER")
 }
 
+// GetEmbeddingDimensions returns the size of stored embedding vectors.
+func GetEmbeddingDimensions() int {
+	return GetEmbeddingDimensionsOf(GetProvider())
+}
+
+// GetEmbeddingDimensionsOf returns the size of the embedding vectors provider
+// produces: its own <provider>_embedding_dimensions if set, else the native
+// size of a well known embedding model, else embedding_dimensions.
+func GetEmbeddingDimensionsOf(provider string) int {
+	provider = strings.ToLower(provider)
+	if dimensions := GetProviderEmbeddingDimensions(provider); dimensions > 0 {
+		return dimensions
+	}
+	if dimensions, ok := modelDimensions[provider+":"+embeddingModelOf(provider)]; ok {
+		return dimensions
+	}
+	return viper.GetInt("embedding_dimensions")
+}
+
+// embeddingModelOf returns the embedding model configured for provider, without
+// the default :latest tag of Ollama models.
+func embeddingModelOf(provider string) string {
+	switch provider {
+	case "openai":
+		return GetOpenAIEmbeddingModel()
+	case "ollama":
+		return strings.TrimSuffix(GetOllamaEmbeddingModel(), ":latest")
+	}
+	return ""
+}
+
+// GetProviderEmbeddingDimensions returns the <provider>_embedding_dimensions
+// setting, 0 if unset. Providers whose models can shorten their vectors only
+// ask for a size when it is set.
+func GetProviderEmbeddingDimensions(provider string) int {
+	return viper.GetInt(strings.ToLower(provider) + "_embedding_dimensions")
+}
+
+// GetEmbedBatchSize returns how many files are embedded per provider request.
+func GetEmbedBatchSize() int {
+	return viper.GetInt("embed_batch_size")
+}
+
+// GetExtractWorkers returns how many files are read and extracted at once
+// while indexing a directory, the number of CPUs when 0 or less.
+func GetExtractWorkers() int {
+	if workers := viper.GetInt("extract_workers"); workers > 0 {
+		return workers
+	}
+	return runtime.NumCPU()
+}
+
+// GetEmbedWorkers returns how many embedding batches are in flight at once
+// while indexing a directory.
+func GetEmbedWorkers() int {
+	return max(viper.GetInt("embed_workers"), 1)
+}
+
+// GetEmbedFlushInterval returns how long queued files may wait before being embedded.
+func GetEmbedFlushInterval() time.Duration {
+	interval := viper.GetDuration("embed_flush_interval")
+	if interval <= 0 {
+		return 2 * time.Second
+	}
+	return interval
+}
+
+// GetChunkSize returns the target chunk length in bytes.
+func GetChunkSize() int {
+	return viper.GetInt("chunk_size")
+}
+
+// GetChunkOverlap returns how many bytes consecutive chunks share.
+func GetChunkOverlap() int {
+	return viper.GetInt("chunk_overlap")
+}
+
+// GetMaxRetries returns how often a failed provider call is retried.
+func GetMaxRetries() int {
+	return viper.GetInt("max_retries")
+}
+
+// GetCircuitBreakerThreshold returns the consecutive failures that pause provider calls.
+func GetCircuitBreakerThreshold() int {
+	return viper.GetInt("circuit_breaker_threshold")
+}
+
+// GetCircuitBreakerCooldown returns how long provider calls stay paused.
+func GetCircuitBreakerCooldown() time.Duration {
+	return viper.GetDuration("circuit_breaker_cooldown")
+}
+
+// GetRequestsPerMinute returns the client-side request limit for provider, 0 if unlimited.
+func GetRequestsPerMinute(provider string) int {
+	return viper.GetInt(strings.ToLower(provider) + "_requests_per_minute")
+}
+
+// GetTokensPerMinute returns the client-side token limit for provider, 0 if unlimited.
+func GetTokensPerMinute(provider string) int {
+	return viper.GetInt(strings.ToLower(provider) + "_tokens_per_minute")
+}
+
+// GetQueryParser returns how search queries are parsed: llm, rules or rules-then-llm.
+func GetQueryParser() string {
+	return viper.GetString("query_parser")
+}
+
+// GetMinScore returns the relevance score search results must reach to be shown.
+func GetMinScore() float64 {
+	return viper.GetFloat64("min_score")
+}
+
+// GetAskMinScore returns the relevance score passages must reach to be used
+// as sources by ask. It is stricter than min_score, as ask rather says it found
+// nothing than answers from unrelated passages.
+func GetAskMinScore() float64 {
+	return viper.GetFloat64("ask_min_score")
+}
+
 // GetWatchPaths returns the list of paths to index.
 func GetWatchPaths() []string {
-	paths := viper.GetStringSlice("watch_paths")
-	// Resolve ~ to absolute paths
-	for i, path := range paths {
-		if strings.HasPrefix(path, "~") {
-			home, _ := os.UserHomeDir()
-			paths[i] = filepath.Join(home, strings.TrimPrefix(path, "~"))
-		}
-		paths[i] = filepath.Clean(paths[i])
-	}
-	return paths
+	return getPaths("watch_paths")
 }
 
 // GetIgnorePatterns returns the list of ignore patterns.
@@ -107,8 +351,27 @@ func GetIgnorePatterns() []string {
 	return viper.GetStringSlice("ignore_patterns")
 }
 
+// GetIgnorePaths returns the absolute paths left out of the index.
 func GetIgnorePaths() []string {
-	return viper.GetStringSlice("ignore_paths")
+	return getPaths("ignore_paths")
+}
+
+// GetFiletypes returns the regular expressions a file's path must match to be indexed.
+func GetFiletypes() []string {
+	return viper.GetStringSlice("filetypes")
+}
+
+// getPaths returns the paths under key, with ~ resolved to the home directory.
+func getPaths(key string) []string {
+	paths := viper.GetStringSlice(key)
+	for i, path := range paths {
+		if strings.HasPrefix(path, "~") {
+			home, _ := os.UserHomeDir()
+			paths[i] = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+		paths[i] = filepath.Clean(paths[i])
+	}
+	return paths
 }
 
 // GetDatabasePath returns the database path.
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestPersistKeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	original := `# Lamina Configuration
+provider: gemini
+# vector size of gemini and local embeddings
+embedding_dimensions: 3072
+# sent to the OpenAI API to shorten vectors, the model's own size if unset
+# openai_embedding_dimensions: 1024
+watch_paths:
+  - ~/Documents
+`
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := configPath
+	configPath = path
+	t.Cleanup(func() { configPath = previous })
+	for _, key := range []string{"provider", "openai_embedding_dimensions", "openai_embedding_model"} {
+		previous := viper.Get(key)
+		t.Cleanup(func() { viper.Set(key, previous) })
+	}
+
+	err := Persist(map[string]any{
+		"provider":                    "openai",
+		"openai_embedding_dimensions": 1536,
+		"openai_embedding_model":      "text-embedding-3-small",
+	})
+	if err != nil {
+		t.Fatalf("Persist: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Lamina Configuration
+provider: openai
+# vector size of gemini and local embeddings
+embedding_dimensions: 3072
+# sent to the OpenAI API to shorten vectors, the model's own size if unset
+openai_embedding_dimensions: 1536
+watch_paths:
+  - ~/Documents
+openai_embedding_model: text-embedding-3-small
+`
+	if string(content) != want {
+		t.Errorf("config after Persist:\n%s\nwant\n%s", content, want)
+	}
+	if got := GetProvider(); got != "openai" {
+		t.Errorf("GetProvider() = %q after Persist", got)
+	}
+
+	if err := Persist(map[string]any{"watch_paths": []string{"/srv"}}); err == nil {
+		t.Error("Persist wrote a list")
+	}
+}
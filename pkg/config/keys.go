@@ -6,30 +6,121 @@ var configPath string
 var stringConfigKeys = []string{
 	"provider",
 	"openai_key",
+	"openai_base_url",
+	"openai_embedding_model",
+	"openai_chat_model",
 	"gemini_key",
+	"gemini_embedding_model",
+	"gemini_chat_model",
+	"ollama_host",
+	"ollama_embedding_model",
+	"ollama_chat_model",
 	"database_path",
+	"embed_flush_interval",
+	"circuit_breaker_cooldown",
+	"query_parser",
 }
 
 var stringSliceConfigKeys = []string{
 	"ignore_patterns",
+	"ignore_paths",
 	"watch_paths",
 	"filetypes",
 }
 
-var totalConfigKeys = append(stringConfigKeys, stringSliceConfigKeys...)
+var intConfigKeys = []string{
+	"embedding_dimensions",
+	"openai_embedding_dimensions",
+	"ollama_embedding_dimensions",
+	"embed_batch_size",
+	"extract_workers",
+	"embed_workers",
+	"chunk_size",
+	"chunk_overlap",
+	"max_retries",
+	"circuit_breaker_threshold",
+	"gemini_requests_per_minute",
+	"gemini_tokens_per_minute",
+	"openai_requests_per_minute",
+	"openai_tokens_per_minute",
+	"ollama_requests_per_minute",
+	"ollama_tokens_per_minute",
+}
+
+// modelDimensions are the native vector sizes of well known embedding models
+// of providers that can't pick a size for any model, by provider:model.
+var modelDimensions = map[string]int{
+	"openai:text-embedding-3-large": 3072,
+	"openai:text-embedding-3-small": 1536,
+	"openai:text-embedding-ada-002": 1536,
+	"ollama:nomic-embed-text":       768,
+	"ollama:mxbai-embed-large":      1024,
+	"ollama:all-minilm":             384,
+	"ollama:bge-m3":                 1024,
+	"ollama:snowflake-arctic-embed": 1024,
+}
+
+var floatConfigKeys = []string{
+	"min_score",
+	"ask_min_score",
+}
+
+var totalConfigKeys = append(append(append(stringConfigKeys, stringSliceConfigKeys...), intConfigKeys...), floatConfigKeys...)
 
 // basic YAML structure with defaults
 var defaultConfig = `
 # Lamina Configuration
 provider: gemini
+# vector size of gemini and local embeddings, and of models of other
+# providers lamina doesn't know the size of
+embedding_dimensions: 3072
+gemini_embedding_model: gemini-embedding-001
+gemini_chat_model: gemini-2.0-flash-exp
+openai_base_url: https://api.openai.com/v1
+openai_embedding_model: text-embedding-3-large
+# sent to the OpenAI API to shorten vectors, the model's own size if unset
+# openai_embedding_dimensions: 1024
+openai_chat_model: gpt-4o-mini
+ollama_host: http://localhost:11434
+ollama_embedding_model: nomic-embed-text
+# vector size of the Ollama model, only needed for models lamina doesn't know
+# ollama_embedding_dimensions: 768
+ollama_chat_model: llama3.2
 database_path: ~/.lamina/lamina.db
+embed_batch_size: 32
+# files extracted at once while indexing, 0 means one per CPU
+extract_workers: 0
+# embedding batches sent to the provider at once while indexing
+embed_workers: 2
+chunk_size: 2000
+chunk_overlap: 200
+embed_flush_interval: 2s
+max_retries: 5
+circuit_breaker_threshold: 5
+circuit_breaker_cooldown: 30s
+# how search understands queries: llm, rules (offline) or rules-then-llm
+query_parser: rules-then-llm
+# hide search results scoring below this (0-1)
+min_score: 0
+# passages scoring below this are not used to answer questions with ask
+ask_min_score: 0.6
+# client-side limits per provider, 0 means unlimited
+gemini_requests_per_minute: 100
+gemini_tokens_per_minute: 0
 watch_paths:
   - ~/Documents
+# globs left out of the index, without a slash they match any file or
+# directory name, with one the full path; ** spans directories
 ignore_patterns:
   - .git
   - node_modules
   - "*.log"
+# absolute paths left out of the index
+ignore_paths: []
+# only files whose path matches one of these regular expressions are indexed
 filetypes:
   - .*\.txt$
   - .*\.md$
+  - .*\.pdf$
+  - .*\.docx$
 `
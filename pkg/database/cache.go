@@ -0,0 +1,189 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	settingCacheHits   = "cache_hits"
+	settingCacheMisses = "cache_misses"
+)
+
+// CacheStatistics summarises the embedding cache.
+type CacheStatistics struct {
+	Entries int64
+	Bytes   int64
+	Hits    int64
+	Misses  int64
+	Models  map[string]int64
+}
+
+// CachedEmbeddings returns the cached vectors of model for the given content hashes, keyed by hash.
+func CachedEmbeddings(model string, hashes []string) (map[string][]float32, error) {
+	if len(hashes) == 0 {
+		return map[string][]float32{}, nil
+	}
+
+	var entries []EmbeddingCache
+	if err := Store.Where("model = ? AND content_hash IN ?", model, hashes).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+
+	vectors := make(map[string][]float32, len(entries))
+	for _, entry := range entries {
+		vectors[entry.ContentHash] = DecodeVector(entry.Vector)
+	}
+	return vectors, nil
+}
+
+// MarkCacheUsed counts a hit on the cached vectors of model for the given
+// content hashes and keeps them from being pruned for a while.
+func MarkCacheUsed(model string, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	err := Store.Model(&EmbeddingCache{}).
+		Where("model = ? AND content_hash IN ?", model, hashes).
+		Updates(map[string]any{"hits": gorm.Expr("hits + 1"), "last_used_at": time.Now()}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update embedding cache: %w", err)
+	}
+	return nil
+}
+
+// CacheEmbedding stores the vector model produced for content hash.
+func CacheEmbedding(contentHash, model string, vector []byte, dimensions int) error {
+	now := time.Now()
+	return Store.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "content_hash"}, {Name: "model"}},
+		DoUpdates: clause.AssignmentColumns([]string{"vector", "dimensions", "last_used_at"}),
+	}).Create(&EmbeddingCache{
+		ContentHash: contentHash,
+		Model:       model,
+		Dimensions:  dimensions,
+		Vector:      vector,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+	}).Error
+}
+
+// RecordCacheLookups adds to the persistent hit and miss counters.
+func RecordCacheLookups(hits, misses int) error {
+	return Store.Transaction(func(tx *gorm.DB) error {
+		if err := incrementSetting(tx, settingCacheHits, hits); err != nil {
+			return err
+		}
+		return incrementSetting(tx, settingCacheMisses, misses)
+	})
+}
+
+// GetCacheStatistics returns the size and hit rate of the embedding cache.
+func GetCacheStatistics() (*CacheStatistics, error) {
+	stats := &CacheStatistics{Models: map[string]int64{}}
+
+	var rows []struct {
+		Model   string
+		Entries int64
+		Bytes   int64
+	}
+	err := Store.Model(&EmbeddingCache{}).
+		Select("model, COUNT(*) AS entries, COALESCE(SUM(LENGTH(vector)), 0) AS bytes").
+		Group("model").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+
+	for _, row := range rows {
+		stats.Models[row.Model] = row.Entries
+		stats.Entries += row.Entries
+		stats.Bytes += row.Bytes
+	}
+
+	if stats.Hits, err = intSetting(settingCacheHits); err != nil {
+		return nil, err
+	}
+	if stats.Misses, err = intSetting(settingCacheMisses); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// PruneCache deletes cached vectors whose content no indexed chunk has and that
+// weren't used within maxAge. With otherModels it also drops every vector not
+// produced by activeModel.
+func PruneCache(maxAge time.Duration, activeModel string, otherModels bool) (int64, error) {
+	var removed int64
+	err := Store.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("content_hash NOT IN (SELECT content_hash FROM chunks) AND last_used_at < ?", time.Now().Add(-maxAge)).
+			Delete(&EmbeddingCache{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed += result.RowsAffected
+
+		if otherModels {
+			result = tx.Where("model <> ?", activeModel).Delete(&EmbeddingCache{})
+			if result.Error != nil {
+				return result.Error
+			}
+			removed += result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune embedding cache: %w", err)
+	}
+	return removed, nil
+}
+
+// ResetCacheStatistics zeroes the hit and miss counters.
+func ResetCacheStatistics() error {
+	if err := SetSetting(settingCacheHits, "0"); err != nil {
+		return err
+	}
+	return SetSetting(settingCacheMisses, "0")
+}
+
+// EncodeVector serializes vector scaled to unit length. Stored vectors and the
+// vectors compared with them all go through it, so the L2 distances vec0
+// computes rank like cosine similarity, see SimilarityScore.
+func EncodeVector(vector []float32) ([]byte, error) {
+	return sqlite_vec.SerializeFloat32(normalizeVector(vector))
+}
+
+// DecodeVector converts a serialized float32 vector back into floats.
+func DecodeVector(blob []byte) []float32 {
+	vector := make([]float32, len(blob)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[i*4:]))
+	}
+	return vector
+}
+
+func intSetting(key string) (int64, error) {
+	value, err := GetSetting(key)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func incrementSetting(db *gorm.DB, key string, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]any{"value": gorm.Expr("CAST(settings.value AS INTEGER) + ?", delta)}),
+	}).Create(&Setting{Key: key, Value: strconv.Itoa(delta)}).Error
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"lamina/pkg/config"
+)
+
+// setConfig overrides a configuration key for the duration of the test.
+func setConfig(t *testing.T, key string, value any) {
+	t.Helper()
+	previous := viper.Get(key)
+	config.Set(key, value)
+	t.Cleanup(func() { config.Set(key, previous) })
+}
+
+// openTestStorage opens a new database of dimensions wide vectors.
+func openTestStorage(t *testing.T, dimensions int) {
+	t.Helper()
+	setConfig(t, "database_path", filepath.Join(t.TempDir(), "lamina.db"))
+	setConfig(t, "provider", "local")
+	setConfig(t, "embedding_dimensions", dimensions)
+	if err := NewStorage(); err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() {
+		if db, err := Store.DB(); err == nil {
+			db.Close()
+		}
+	})
+}
+
+func TestPruneCacheKeepsIndexedChunks(t *testing.T) {
+	openTestStorage(t, 3)
+
+	vector, err := EncodeVector([]float32{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := []Chunk{{Text: "first", ContentHash: "chunk-1"}, {Ordinal: 1, Text: "second", ContentHash: "chunk-2"}}
+	file := &File{Path: "/notes/a.txt", ContentHash: "file-a", Content: "first second"}
+	if err := SaveFileWithChunks(file, chunks, [][]byte{vector, vector}); err != nil {
+		t.Fatalf("SaveFileWithChunks: %v", err)
+	}
+
+	// Whole file vectors were cached before chunking, nothing uses them now
+	for _, hash := range []string{"chunk-1", "chunk-2", "file-a", "gone"} {
+		if err := CacheEmbedding(hash, "local:test", vector, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := PruneCache(0, "local:test", false)
+	if err != nil {
+		t.Fatalf("PruneCache: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("PruneCache removed %d entries, want 2", removed)
+	}
+
+	var kept []string
+	if err := Store.Model(&EmbeddingCache{}).Order("content_hash").Pluck("content_hash", &kept).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"chunk-1", "chunk-2"}; !slices.Equal(kept, want) {
+		t.Errorf("kept %q, want %q", kept, want)
+	}
+}
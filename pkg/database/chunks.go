@@ -0,0 +1,168 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveFileWithChunks upserts file by path and replaces its chunks and their
+// vectors in the active vector table, all in one transaction. vectors holds
+// one serialized embedding per chunk.
+func SaveFileWithChunks(file *File, chunks []Chunk, vectors [][]byte) error {
+	if len(chunks) != len(vectors) {
+		return fmt.Errorf("got %d vectors for %d chunks", len(vectors), len(chunks))
+	}
+
+	return Store.Transaction(func(tx *gorm.DB) error {
+		table, err := vectorTable(tx)
+		if err != nil {
+			return err
+		}
+
+		// The full text index needs the old values to remove them
+		var old File
+		found := tx.Where("path = ?", file.Path).Limit(1).Find(&old)
+		if found.Error != nil {
+			return found.Error
+		}
+
+		// Use ON CONFLICT DO UPDATE for proper upsert
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "path"}},
+			DoUpdates: clause.AssignmentColumns([]string{"content_hash", "size", "mod_time", "content", "device", "inode", "embedding_model", "embedding_dimensions", "updated_at"}),
+		}).Create(file).Error; err != nil {
+			return err
+		}
+
+		previous := &old
+		if found.RowsAffected == 0 {
+			previous = nil
+		}
+		if err := indexFullText(tx, previous, file); err != nil {
+			return err
+		}
+
+		if err := deleteChunks(tx, table, []uint{file.ID}); err != nil {
+			return err
+		}
+
+		for idx := range chunks {
+			chunks[idx].ID = 0
+			chunks[idx].FileID = file.ID
+		}
+		if len(chunks) > 0 {
+			if err := tx.Create(&chunks).Error; err != nil {
+				return fmt.Errorf("failed to save chunks: %w", err)
+			}
+		}
+
+		for idx, chunk := range chunks {
+			if err := upsertVector(tx, table, chunk.ID, vectors[idx]); err != nil {
+				return fmt.Errorf("failed to save chunk vector: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ChunksByID loads the chunks with the given IDs, keyed by ID.
+func ChunksByID(ids []uint) (map[uint]Chunk, error) {
+	var chunks []Chunk
+	if err := Store.Where("id IN ?", ids).Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]Chunk, len(chunks))
+	for _, chunk := range chunks {
+		byID[chunk.ID] = chunk
+	}
+	return byID, nil
+}
+
+// deleteChunks removes the chunks of files and their vectors in table.
+func deleteChunks(tx *gorm.DB, table string, fileIDs []uint) error {
+	if err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE chunk_id IN (SELECT id FROM chunks WHERE file_id IN ?)`, table), fileIDs).Error; err != nil {
+		return fmt.Errorf("failed to delete chunk vectors: %w", err)
+	}
+	if err := tx.Where("file_id IN ?", fileIDs).Delete(&Chunk{}).Error; err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return nil
+}
+
+// deleteBatchSize bounds the files removed per statement, well below SQLite's variable limit.
+const deleteBatchSize = 500
+
+// DeleteFiles removes the file at path, or every file below path if it was
+// a directory, along with their chunks, vectors and full text entries in one
+// transaction. It returns how many files were removed.
+func DeleteFiles(path string) (int, error) {
+	path = strings.TrimSuffix(path, "/")
+	removed := 0
+	err := Store.Transaction(func(tx *gorm.DB) error {
+		table, err := vectorTable(tx)
+		if err != nil {
+			return err
+		}
+
+		var batch []File
+		result := tx.Where(atOrBelow(path)).
+			FindInBatches(&batch, deleteBatchSize, func(batchTx *gorm.DB, _ int) error {
+				if err := deleteFileRows(tx, table, batch); err != nil {
+					return err
+				}
+				removed += len(batch)
+				return nil
+			})
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// IndexedPaths returns the paths of the indexed files at or below dir, of
+// all of them if dir is empty.
+func IndexedPaths(dir string) ([]string, error) {
+	query := Store.Model(&File{})
+	if dir = strings.TrimSuffix(dir, "/"); dir != "" {
+		query = query.Where(atOrBelow(dir))
+	}
+
+	var paths []string
+	if err := query.Pluck("path", &paths).Error; err != nil {
+		return nil, fmt.Errorf("failed to list indexed files: %w", err)
+	}
+	return paths, nil
+}
+
+// atOrBelow matches the file at path and the files below it. LIKE would
+// ignore case, so the paths below are the range from path/ up to path0, '0'
+// following '/', which compares bytes and uses the path index.
+func atOrBelow(path string) clause.Expr {
+	return gorm.Expr("path = ? OR (path >= ? AND path < ?)", path, path+"/", path+"0")
+}
+
+// deleteFileRows removes files, which must hold the values indexed for full
+// text search, with their chunks and vectors in table.
+func deleteFileRows(tx *gorm.DB, table string, files []File) error {
+	ids := make([]uint, len(files))
+	for idx := range files {
+		ids[idx] = files[idx].ID
+		if err := removeFullText(tx, &files[idx]); err != nil {
+			return err
+		}
+	}
+
+	if err := deleteChunks(tx, table, ids); err != nil {
+		return err
+	}
+	if err := tx.Delete(&File{}, ids).Error; err != nil {
+		return fmt.Errorf("failed to delete files: %w", err)
+	}
+	return nil
+}
@@ -12,35 +12,70 @@ import (
 
 var Store *gorm.DB
 
+// NewStorage opens the database and makes sure the active vector table fits
+// the configured embeddings.
 func NewStorage() error {
+	if err := OpenStorage(); err != nil {
+		return err
+	}
+	return setupVectorTable()
+}
+
+// OpenStorage opens and migrates the database without touching the vector
+// table, for reindex, which replaces it whatever its width.
+func OpenStorage() error {
 	sqlite_vec.Auto() // Enable sqlite-vec functions
 
 	dbPath := config.GetDatabasePath()
 
 	var err error
 
+	// Transactions take the write lock when they begin, so one reading the
+	// active vector table can't be overtaken by a reindex swapping it
 	Store, err = gorm.Open(sqlite.Dialector{
 		DriverName: "sqlite3",
-		DSN:        dbPath,
+		DSN:        dbPath + "?_txlock=immediate",
 	}, &gorm.Config{})
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := Store.Exec(`
-        CREATE VIRTUAL TABLE IF NOT EXISTS vec_embeddings USING vec0(
-            file_id INTEGER PRIMARY KEY,
-            embedding FLOAT[3072]
-        )
-	`).Error; err != nil {
-		return fmt.Errorf("failed to create vector table: %w", err)
-	}
-
 	// Run migrations
-	if err := Store.AutoMigrate(&File{}); err != nil {
+	if err := Store.AutoMigrate(&File{}, &Chunk{}, &Setting{}, &EmbeddingCache{}); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	return setupFullText()
+}
+
+// setupVectorTable creates the active vector table if needed, upgrades and
+// normalizes old vectors, and refuses a table of another width.
+func setupVectorTable() error {
+	table, err := VectorTable()
+	if err != nil {
+		return err
+	}
+
+	dimensions := config.GetEmbeddingDimensions()
+	if err := CreateVectorTable(table, dimensions); err != nil {
+		return err
+	}
+
+	if err := upgradeLegacyVectorTable(table, dimensions); err != nil {
+		return err
+	}
+
+	// An existing table keeps the width it was created with
+	if existing, err := vectorTableDimensions(table); err != nil {
+		return err
+	} else if existing != dimensions {
+		return fmt.Errorf("vector table stores %d dimensional embeddings but %s embeddings have %d; restore the embedding settings or run `lamina reindex`", existing, config.GetProvider(), dimensions)
+	}
+
+	if err := normalizeStoredVectors(table); err != nil {
+		return err
+	}
+
 	// Verify sqlite-vec extension
 	// var vecVersion string
 	// if err := Store.Raw("SELECT vec_version()").Scan(&vecVersion).Error; err != nil {
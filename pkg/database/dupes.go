@@ -0,0 +1,272 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Duplicate cluster kinds
+const (
+	DuplicateExact = "exact"
+	DuplicateNear  = "near"
+)
+
+// DuplicateFile is a member of a DuplicateCluster.
+type DuplicateFile struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	ContentHash string    `json:"content_hash"`
+}
+
+// DuplicateCluster is a group of files with identical or nearly identical content.
+type DuplicateCluster struct {
+	// Kind is DuplicateExact when all files have the same content, else DuplicateNear
+	Kind string `json:"kind"`
+	// Score is the lowest similarity of the pairs that joined the cluster, 1 for exact duplicates
+	Score float64 `json:"score"`
+	// Files are ordered newest first
+	Files []DuplicateFile `json:"files"`
+}
+
+// duplicateNeighbours is how many chunks nearest to the mean vector of a file
+// are looked up for near-duplicates of it.
+const duplicateNeighbours = 64
+
+// FindDuplicates groups files with the same content hash into exact clusters
+// and, unless minScore is above 1, contents whose mean chunk vectors are at
+// least minScore similar into near clusters. A near cluster holds every file
+// of its contents, so copies are in an exact cluster as well. Clusters are
+// ordered by the number of files, largest first.
+func FindDuplicates(minScore float64) ([]DuplicateCluster, error) {
+	var files []File
+	err := Store.Select("id", "path", "size", "mod_time", "content_hash").Order("path").Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Identical content has identical vectors, so compare each content once
+	var hashes []string
+	byHash := map[string][]File{}
+	for _, file := range files {
+		if file.ContentHash == "" {
+			continue
+		}
+		if _, seen := byHash[file.ContentHash]; !seen {
+			hashes = append(hashes, file.ContentHash)
+		}
+		byHash[file.ContentHash] = append(byHash[file.ContentHash], file)
+	}
+
+	var clusters []DuplicateCluster
+	for _, hash := range hashes {
+		if len(byHash[hash]) > 1 {
+			clusters = append(clusters, newDuplicateCluster(DuplicateExact, 1, byHash[hash]))
+		}
+	}
+
+	if minScore <= 1 {
+		groups, scores, err := nearDuplicates(byHash, hashes, minScore)
+		if err != nil {
+			return nil, err
+		}
+		for idx, group := range groups {
+			var members []File
+			for _, content := range group {
+				members = append(members, byHash[hashes[content]]...)
+			}
+			clusters = append(clusters, newDuplicateCluster(DuplicateNear, scores[idx], members))
+		}
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Files) > len(clusters[j].Files)
+	})
+	return clusters, nil
+}
+
+// newDuplicateCluster returns a cluster of files, newest first.
+func newDuplicateCluster(kind string, score float64, files []File) DuplicateCluster {
+	cluster := DuplicateCluster{Kind: kind, Score: score}
+	for _, file := range files {
+		cluster.Files = append(cluster.Files, DuplicateFile{
+			Path:        file.Path,
+			Size:        file.Size,
+			ModTime:     file.ModTime,
+			ContentHash: file.ContentHash,
+		})
+	}
+	sort.SliceStable(cluster.Files, func(i, j int) bool {
+		return cluster.Files[i].ModTime.After(cluster.Files[j].ModTime)
+	})
+	return cluster
+}
+
+// contentPair is two contents by index into the hashes, the lower first.
+type contentPair [2]int
+
+func pairOf(a, b int) contentPair {
+	return contentPair{min(a, b), max(a, b)}
+}
+
+// nearDuplicates groups contents, by index into hashes, whose mean chunk
+// vectors are at least minScore similar and returns the groups with their
+// lowest pair score.
+//
+// Candidates of each content are the files of the chunks nearest to its mean
+// vector. A near-duplicate may have no single chunk that close, so minScore
+// applies to the mean vectors of both rather than to the neighbours.
+//
+// Groups are formed with complete linkage: two groups merge only if every
+// content of one is similar enough to every content of the other, so loosely
+// related files don't chain together.
+func nearDuplicates(byHash map[string][]File, hashes []string, minScore float64) ([][]int, []float64, error) {
+	table, err := VectorTable()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contentOf := map[uint]int{}
+	for idx, hash := range hashes {
+		for _, file := range byHash[hash] {
+			contentOf[file.ID] = idx
+		}
+	}
+	// Copies share vectors, the first file stands for all of them
+	vectorOf := func(content int) ([]float32, error) {
+		return FileVector(byHash[hashes[content]][0].ID)
+	}
+
+	scores := map[contentPair]float64{}
+	compared := map[contentPair]bool{}
+	for content := range hashes {
+		vector, err := vectorOf(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		if vector == nil {
+			continue
+		}
+		blob, err := EncodeVector(vector)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var fileIDs []uint
+		err = Store.Table(fmt.Sprintf(`(
+			SELECT chunk_id FROM %s
+			WHERE embedding MATCH ? AND k = ?
+		) v`, table), blob, duplicateNeighbours).
+			Joins("JOIN chunks ON chunks.id = v.chunk_id").
+			Distinct().
+			Pluck("chunks.file_id", &fileIDs).Error
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, fileID := range fileIDs {
+			other, ok := contentOf[fileID]
+			pair := pairOf(content, other)
+			if !ok || other == content || compared[pair] {
+				continue
+			}
+			compared[pair] = true
+
+			otherVector, err := vectorOf(other)
+			if err != nil {
+				return nil, nil, err
+			}
+			if otherVector == nil {
+				continue
+			}
+			// Distance between unit vectors from their dot product
+			score := SimilarityScore(math.Sqrt(max(0, 2-2*dot(vector, otherVector))))
+			if score >= minScore {
+				scores[pair] = score
+			}
+		}
+	}
+
+	pairs := make([]contentPair, 0, len(scores))
+	for pair := range scores {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if scores[pairs[i]] != scores[pairs[j]] {
+			return scores[pairs[i]] > scores[pairs[j]]
+		}
+		return pairs[i][0] < pairs[j][0] || pairs[i][0] == pairs[j][0] && pairs[i][1] < pairs[j][1]
+	})
+
+	// Most similar pairs first, each content starts as a group of its own
+	groupOf := map[int]int{}
+	members := map[int][]int{}
+	lowest := map[int]float64{}
+	for _, pair := range pairs {
+		a, b := pair[0], pair[1]
+		for _, content := range pair {
+			if _, ok := groupOf[content]; !ok {
+				groupOf[content] = content
+				members[content] = []int{content}
+				lowest[content] = 1
+			}
+		}
+		ga, gb := groupOf[a], groupOf[b]
+		if ga == gb {
+			continue
+		}
+
+		linked, score := true, lowest[ga]
+		for _, x := range members[ga] {
+			for _, y := range members[gb] {
+				pairScore, ok := scores[pairOf(x, y)]
+				if !ok {
+					linked = false
+					break
+				}
+				score = min(score, pairScore)
+			}
+			if !linked {
+				break
+			}
+		}
+		if !linked {
+			continue
+		}
+
+		ga, gb = min(ga, gb), max(ga, gb)
+		for _, content := range members[gb] {
+			groupOf[content] = ga
+		}
+		members[ga] = append(members[ga], members[gb]...)
+		lowest[ga] = min(score, lowest[gb])
+		delete(members, gb)
+	}
+
+	var roots []int
+	for root := range members {
+		if len(members[root]) > 1 {
+			roots = append(roots, root)
+		}
+	}
+	sort.Ints(roots)
+
+	groups := make([][]int, len(roots))
+	groupScores := make([]float64, len(roots))
+	for idx, root := range roots {
+		groups[idx] = members[root]
+		sort.Ints(groups[idx])
+		groupScores[idx] = lowest[root]
+	}
+	return groups, groupScores, nil
+}
+
+func dot(a, b []float32) float64 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return float64(sum)
+}
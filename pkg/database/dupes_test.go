@@ -0,0 +1,81 @@
+package database
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// saveTestFile indexes a file of one chunk with vector.
+func saveTestFile(t *testing.T, path, contentHash string, vector []float32) {
+	t.Helper()
+	blob, err := EncodeVector(vector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &File{Path: path, ContentHash: contentHash, Content: path}
+	chunks := []Chunk{{Text: path, ContentHash: contentHash}}
+	if err := SaveFileWithChunks(file, chunks, [][]byte{blob}); err != nil {
+		t.Fatalf("SaveFileWithChunks(%s): %v", path, err)
+	}
+}
+
+// angled returns the unit vector in the y-z plane at degrees from the y axis.
+func angled(degrees float64) []float32 {
+	radians := degrees * math.Pi / 180
+	return []float32{0, float32(math.Cos(radians)), float32(math.Sin(radians))}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	openTestStorage(t, 3)
+
+	saveTestFile(t, "/a/copy-1.txt", "a", []float32{1, 0, 0})
+	saveTestFile(t, "/a/copy-2.txt", "a", []float32{1, 0, 0})
+	saveTestFile(t, "/a/edited.txt", "b", []float32{1, 0.05, 0})
+
+	// Neighbours are similar enough, the ends of the chain aren't
+	saveTestFile(t, "/chain/c.txt", "c", angled(0))
+	saveTestFile(t, "/chain/d.txt", "d", angled(30))
+	saveTestFile(t, "/chain/e.txt", "e", angled(60))
+
+	clusters, err := FindDuplicates(0.9)
+	if err != nil {
+		t.Fatalf("FindDuplicates: %v", err)
+	}
+
+	type cluster struct {
+		kind  string
+		paths []string
+	}
+	var got []cluster
+	for _, c := range clusters {
+		var paths []string
+		for _, file := range c.Files {
+			paths = append(paths, file.Path)
+		}
+		slices.Sort(paths)
+		got = append(got, cluster{c.Kind, paths})
+		if c.Score < 0.9 || c.Score > 1 {
+			t.Errorf("%s cluster %q scores %f", c.Kind, paths, c.Score)
+		}
+	}
+
+	want := []cluster{
+		{DuplicateNear, []string{"/a/copy-1.txt", "/a/copy-2.txt", "/a/edited.txt"}},
+		{DuplicateExact, []string{"/a/copy-1.txt", "/a/copy-2.txt"}},
+		{DuplicateNear, []string{"/chain/c.txt", "/chain/d.txt"}},
+	}
+	if !slices.EqualFunc(got, want, func(a, b cluster) bool {
+		return a.kind == b.kind && slices.Equal(a.paths, b.paths)
+	}) {
+		t.Errorf("FindDuplicates(0.9) = %v, want %v", got, want)
+	}
+
+	exact, err := FindDuplicates(2)
+	if err != nil {
+		t.Fatalf("FindDuplicates: %v", err)
+	}
+	if len(exact) != 1 || exact[0].Kind != DuplicateExact {
+		t.Errorf("FindDuplicates(2) = %+v, want the exact cluster only", exact)
+	}
+}
@@ -0,0 +1,306 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	settingEmbeddingModel      = "embedding_model"
+	settingEmbeddingDimensions = "embedding_dimensions"
+	settingVectorTable         = "vector_table"
+	settingVectorsNormalized   = "vectors_normalized"
+
+	// DefaultVectorTable is the vector table used until the first reindex.
+	DefaultVectorTable = "vec_embeddings"
+)
+
+// ErrModelMismatch is returned when vectors from different embedding models would be compared.
+var ErrModelMismatch = errors.New("embedding model mismatch")
+
+// ErrVectorTableBehind is returned when swapping in a vector table that lacks chunks added meanwhile.
+var ErrVectorTableBehind = errors.New("vector table is missing recently added chunks")
+
+var vectorWidthPattern = regexp.MustCompile(`(?i)FLOAT\[(\d+)\]`)
+
+// GetSetting returns the value stored under key, or "" if unset.
+func GetSetting(key string) (string, error) {
+	return getSetting(Store, key)
+}
+
+func getSetting(db *gorm.DB, key string) (string, error) {
+	// Find instead of First so missing settings aren't logged as errors
+	var setting Setting
+	err := db.Where("key = ?", key).Limit(1).Find(&setting).Error
+	return setting.Value, err
+}
+
+// SetSetting stores value under key.
+func SetSetting(key, value string) error {
+	return setSetting(Store, key, value)
+}
+
+func setSetting(db *gorm.DB, key, value string) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value"}),
+	}).Create(&Setting{Key: key, Value: value}).Error
+}
+
+// VectorTable returns the name of the vector table searches and indexing use.
+// It is read on every call so long running processes pick up a reindex swap.
+func VectorTable() (string, error) {
+	return vectorTable(Store)
+}
+
+// vectorTable reads the active vector table within db. Writers to it read it
+// in their transaction, so they can't write to a table a reindex swapped out.
+func vectorTable(db *gorm.DB) (string, error) {
+	table, err := getSetting(db, settingVectorTable)
+	if err != nil {
+		return "", fmt.Errorf("failed to read active vector table: %w", err)
+	}
+	if table == "" {
+		return DefaultVectorTable, nil
+	}
+	return table, nil
+}
+
+// CreateVectorTable creates a vec0 table holding vectors of the given width.
+func CreateVectorTable(table string, dimensions int) error {
+	if dimensions <= 0 {
+		return fmt.Errorf("invalid embedding dimensions %d", dimensions)
+	}
+
+	if err := Store.Exec(fmt.Sprintf(`
+        CREATE VIRTUAL TABLE IF NOT EXISTS %s USING vec0(
+            chunk_id INTEGER PRIMARY KEY,
+            embedding FLOAT[%d]
+        )
+	`, table, dimensions)).Error; err != nil {
+		return fmt.Errorf("failed to create vector table %s: %w", table, err)
+	}
+	return nil
+}
+
+// DropVectorTable removes a vector table if it exists.
+func DropVectorTable(table string) error {
+	return Store.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", table)).Error
+}
+
+// UpsertVector stores the embedding of a chunk in table, replacing any previous one.
+func UpsertVector(table string, chunkID uint, vector []byte) error {
+	return upsertVector(Store, table, chunkID, vector)
+}
+
+func upsertVector(db *gorm.DB, table string, chunkID uint, vector []byte) error {
+	// vec0 tables don't support ON CONFLICT, so try to update first
+	result := db.Exec(fmt.Sprintf(`UPDATE %s SET embedding = ? WHERE chunk_id = ?`, table), vector, chunkID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// If no rows were affected, insert new record
+	if result.RowsAffected == 0 {
+		return db.Exec(fmt.Sprintf(`INSERT INTO %s(chunk_id, embedding) VALUES (?, ?)`, table), chunkID, vector).Error
+	}
+	return nil
+}
+
+// SwapVectorTable atomically makes table, holding the vectors of every chunk
+// up to embeddedThrough, the active vector table for model. Vectors of chunks
+// removed meanwhile are dropped from it. If chunks were added after
+// embeddedThrough it returns ErrVectorTableBehind and changes nothing.
+// The previously active table is returned so the caller can drop it.
+func SwapVectorTable(table, model string, dimensions int, embeddedThrough uint) (string, error) {
+	var previous string
+	err := Store.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if previous, err = vectorTable(tx); err != nil {
+			return err
+		}
+
+		// Indexing writes to the active table in transactions of its own, so
+		// none can commit between this check and the swap
+		var added int64
+		if err := tx.Model(&Chunk{}).Where("id > ?", embeddedThrough).Count(&added).Error; err != nil {
+			return err
+		}
+		if added > 0 {
+			return ErrVectorTableBehind
+		}
+		if err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE chunk_id NOT IN (SELECT id FROM chunks)`, table)).Error; err != nil {
+			return fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+
+		if err := setSetting(tx, settingVectorTable, table); err != nil {
+			return err
+		}
+		if err := setSetting(tx, settingEmbeddingModel, model); err != nil {
+			return err
+		}
+		if err := setSetting(tx, settingEmbeddingDimensions, strconv.Itoa(dimensions)); err != nil {
+			return err
+		}
+		return tx.Model(&File{}).Where("1 = 1").
+			Updates(map[string]any{"embedding_model": model, "embedding_dimensions": dimensions}).Error
+	})
+	if errors.Is(err, ErrVectorTableBehind) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to swap vector table: %w", err)
+	}
+	return previous, nil
+}
+
+// IndexedModel returns the embedding model and dimensions recorded for the vector table.
+func IndexedModel() (string, int, error) {
+	model, err := GetSetting(settingEmbeddingModel)
+	if err != nil {
+		return "", 0, err
+	}
+
+	value, err := GetSetting(settingEmbeddingDimensions)
+	if err != nil || value == "" {
+		return model, 0, err
+	}
+
+	dimensions, err := strconv.Atoi(value)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid recorded embedding dimensions %q: %w", value, err)
+	}
+	return model, dimensions, nil
+}
+
+// CheckEmbeddingModel refuses to go on when the vector table holds embeddings
+// from a different model or dimension. The first model used is recorded, and
+// vectors stored before models were tracked are assumed to belong to it.
+func CheckEmbeddingModel(model string, dimensions int) error {
+	indexedModel, indexedDimensions, err := IndexedModel()
+	if err != nil {
+		return fmt.Errorf("failed to read indexed embedding model: %w", err)
+	}
+
+	if indexedModel == "" {
+		// Concurrent embedders must never see the model without its dimensions
+		return Store.Transaction(func(tx *gorm.DB) error {
+			if err := setSetting(tx, settingEmbeddingModel, model); err != nil {
+				return err
+			}
+			if err := setSetting(tx, settingEmbeddingDimensions, strconv.Itoa(dimensions)); err != nil {
+				return err
+			}
+			return tx.Model(&File{}).Where("embedding_model = ? OR embedding_model IS NULL", "").
+				Updates(map[string]any{"embedding_model": model, "embedding_dimensions": dimensions}).Error
+		})
+	}
+
+	if indexedModel != model || indexedDimensions != dimensions {
+		return fmt.Errorf("%w: index holds %s (%d dims) but provider uses %s (%d dims); switch back or run `lamina reindex`",
+			ErrModelMismatch, indexedModel, indexedDimensions, model, dimensions)
+	}
+	return nil
+}
+
+// upgradeLegacyVectorTable replaces a vector table holding one vector per file
+// with a per-chunk one. The old vectors can't be mapped onto chunks, so every
+// file is marked as changed and gets re-embedded by the next index run.
+func upgradeLegacyVectorTable(table string, dimensions int) error {
+	schema, err := vectorTableSchema(table)
+	if err != nil || !strings.Contains(schema, "file_id") {
+		return err
+	}
+
+	fmt.Println("⚠️ Upgrading vector index to per-chunk embeddings; files will be re-embedded on the next index run or `lamina reindex`")
+
+	if err := DropVectorTable(table); err != nil {
+		return fmt.Errorf("failed to drop legacy vector table: %w", err)
+	}
+	if err := CreateVectorTable(table, dimensions); err != nil {
+		return err
+	}
+	return Store.Model(&File{}).Where("1 = 1").Update("content_hash", "").Error
+}
+
+// normalizeStoredVectors scales the vectors stored before EncodeVector
+// existed to unit length, once per database.
+func normalizeStoredVectors(table string) error {
+	done, err := GetSetting(settingVectorsNormalized)
+	if err != nil || done == "1" {
+		return err
+	}
+
+	// One scan to find them, as vec0 only looks up single chunk IDs quickly
+	rows, err := Store.Raw(fmt.Sprintf(`SELECT chunk_id, embedding FROM %s`, table)).Rows()
+	if err != nil {
+		return fmt.Errorf("failed to read stored vectors: %w", err)
+	}
+	var stale []uint
+	for rows.Next() {
+		var chunkID uint
+		var blob []byte
+		if err := rows.Scan(&chunkID, &blob); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read stored vectors: %w", err)
+		}
+		vector := DecodeVector(blob)
+		if norm := dot(vector, vector); norm > 0 && math.Abs(norm-1) > 1e-4 {
+			stale = append(stale, chunkID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read stored vectors: %w", err)
+	}
+
+	err = Store.Transaction(func(tx *gorm.DB) error {
+		for _, chunkID := range stale {
+			var blob []byte
+			if err := tx.Raw(fmt.Sprintf(`SELECT embedding FROM %s WHERE chunk_id = ?`, table), chunkID).Row().Scan(&blob); err != nil {
+				return err
+			}
+			blob, err := EncodeVector(DecodeVector(blob))
+			if err != nil {
+				return err
+			}
+			if err := upsertVector(tx, table, chunkID, blob); err != nil {
+				return err
+			}
+		}
+		return setSetting(tx, settingVectorsNormalized, "1")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to normalize stored vectors: %w", err)
+	}
+	return nil
+}
+
+func vectorTableSchema(table string) (string, error) {
+	var schema string
+	if err := Store.Raw(`SELECT sql FROM sqlite_master WHERE name = ?`, table).Scan(&schema).Error; err != nil {
+		return "", fmt.Errorf("failed to read vector table schema: %w", err)
+	}
+	return schema, nil
+}
+
+// vectorTableDimensions reads the embedding width from a vector table's schema.
+func vectorTableDimensions(table string) (int, error) {
+	schema, err := vectorTableSchema(table)
+	if err != nil {
+		return 0, err
+	}
+
+	match := vectorWidthPattern.FindStringSubmatch(schema)
+	if match == nil {
+		return 0, fmt.Errorf("could not determine vector table width from %q", schema)
+	}
+	return strconv.Atoi(match[1])
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const settingFullTextStale = "full_text_stale"
+
+// ErrLexicalUnavailable is returned for lexical searches when SQLite was built without FTS5.
+var ErrLexicalUnavailable = errors.New("lexical search needs a build with FTS5 (go build -tags sqlite_fts5)")
+
+// lexicalAvailable reports whether the files_fts table could be set up.
+var lexicalAvailable bool
+
+// fileHit is a file found by the lexical search.
+type fileHit struct {
+	FileID uint
+	Score  float64
+}
+
+// setupFullText creates the FTS5 index over file paths and contents. It
+// mirrors the files table (external content) and is kept in sync by the Go
+// code rather than triggers, so builds without FTS5 can still write to files.
+// Those builds flag the index as stale and it is rebuilt on the next start
+// with FTS5 available.
+func setupFullText() error {
+	err := Store.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS files_fts USING fts5(
+			path, content,
+			content='files', content_rowid='id'
+		)
+	`).Error
+	if err == nil {
+		// A table created by another build exists even when this one lacks FTS5
+		err = Store.Exec(`SELECT rowid FROM files_fts LIMIT 0`).Error
+	}
+	if err != nil {
+		// Built without FTS5, lexical search is disabled
+		lexicalAvailable = false
+		return nil
+	}
+	lexicalAvailable = true
+
+	stale, err := GetSetting(settingFullTextStale)
+	if err != nil {
+		return err
+	}
+
+	// Populate a new index, or catch up with writes made without FTS5
+	if stale != "0" {
+		if err := Store.Exec(`INSERT INTO files_fts(files_fts) VALUES('rebuild')`).Error; err != nil {
+			return fmt.Errorf("failed to rebuild full text index: %w", err)
+		}
+	}
+	return SetSetting(settingFullTextStale, "0")
+}
+
+// LexicalSearchAvailable reports whether lexical search can be used.
+func LexicalSearchAvailable() bool {
+	return lexicalAvailable
+}
+
+// indexFullText replaces the full text entry of file. old is the previously
+// stored row with the same ID, nil for new files.
+func indexFullText(tx *gorm.DB, old *File, file *File) error {
+	if !lexicalAvailable {
+		return setSetting(tx, settingFullTextStale, "1")
+	}
+
+	if old != nil {
+		if err := removeFullText(tx, old); err != nil {
+			return err
+		}
+	}
+	err := tx.Exec(`INSERT INTO files_fts(rowid, path, content) VALUES (?, ?, ?)`, file.ID, file.Path, file.Content).Error
+	if err != nil {
+		return fmt.Errorf("failed to update full text index: %w", err)
+	}
+	return nil
+}
+
+// removeFullText drops the full text entry of file, which must hold the
+// values that were indexed.
+func removeFullText(tx *gorm.DB, file *File) error {
+	if !lexicalAvailable {
+		return setSetting(tx, settingFullTextStale, "1")
+	}
+
+	err := tx.Exec(`INSERT INTO files_fts(files_fts, rowid, path, content) VALUES ('delete', ?, ?, ?)`, file.ID, file.Path, file.Content).Error
+	if err != nil {
+		return fmt.Errorf("failed to update full text index: %w", err)
+	}
+	return nil
+}
+
+// lexicalSearch ranks the files passing the filters of params by BM25 over
+// the terms of the query, best first.
+func lexicalSearch(params *SearchParams, limit int) ([]fileHit, error) {
+	if !lexicalAvailable {
+		return nil, ErrLexicalUnavailable
+	}
+
+	match := fullTextQuery(params.SemanticQuery)
+	if match == "" {
+		return nil, nil
+	}
+
+	// bm25() is lower for better matches
+	query := Store.Table("files_fts").
+		Select("files_fts.rowid AS file_id, bm25(files_fts) AS score").
+		Joins("JOIN files ON files.id = files_fts.rowid").
+		Where("files_fts MATCH ?", match)
+
+	var hits []fileHit
+	err := filterFiles(query, params).Order("score").Limit(limit).Scan(&hits).Error
+	if err != nil {
+		return nil, fmt.Errorf("lexical search failed: %w", err)
+	}
+	return hits, nil
+}
+
+// fullTextQuery turns free text into an FTS5 query matching any of its terms.
+// Terms are quoted so identifiers like INV-20391 aren't read as FTS syntax.
+func fullTextQuery(text string) string {
+	terms := queryTerms(text)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// queryTerms splits text into search terms on whitespace and punctuation that
+// can't be part of an identifier.
+func queryTerms(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.'
+	})
+
+	var terms []string
+	for _, field := range fields {
+		field = strings.Trim(field, "-_.")
+		if field != "" {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// inlineKeys are the filter keys of the inline query syntax.
+var inlineKeys = map[string]bool{
+	"type":     true,
+	"ext":      true,
+	"modified": true,
+	"size":     true,
+	"path":     true,
+	"limit":    true,
+}
+
+// ParseInlineQuery parses the inline filter syntax, e.g.
+//
+//	type:pdf,docx modified:>2025-01-01 size:<5MB path:work -path:archive "exact phrase"
+//
+// without any provider. Quoted phrases must occur in the content verbatim;
+// they and the remaining free text make up the semantic query. found reports
+// whether query used any filter key, otherwise it's left to the natural
+// language parsers (which still get the phrases).
+//
+// Comparisons are >, >=, <, <= or a range a..b. A date without one matches
+// its whole day, month (2025-03) or year (2025); relative ages like 7d, 2w,
+// 3m or 1y count back from now.
+func ParseInlineQuery(query string, now time.Time) (params *SearchParams, found bool, err error) {
+	params = &SearchParams{Limit: 10}
+
+	var text []string
+	for _, token := range splitInlineQuery(query) {
+		if token.phrase {
+			params.Phrases = append(params.Phrases, token.value)
+			text = append(text, token.value)
+			continue
+		}
+
+		key, value, hasKey := strings.Cut(token.value, ":")
+		negated := strings.HasPrefix(key, "-")
+		key = strings.ToLower(strings.TrimPrefix(key, "-"))
+		if !hasKey || !inlineKeys[key] {
+			text = append(text, token.value)
+			continue
+		}
+		found = true
+
+		if negated && key != "path" {
+			return nil, true, fmt.Errorf("-%s: only path: can be negated", key)
+		}
+
+		value = strings.Trim(value, `"`)
+		if value == "" {
+			return nil, true, fmt.Errorf("%s: needs a value", key)
+		}
+
+		switch key {
+		case "type", "ext":
+			for _, fileType := range strings.Split(value, ",") {
+				if fileType = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(fileType)), "."); fileType != "" {
+					params.FileTypes = append(params.FileTypes, fileType)
+				}
+			}
+
+		case "path":
+			if negated {
+				params.PathExcludes = append(params.PathExcludes, value)
+			} else {
+				params.PathContains = append(params.PathContains, value)
+			}
+
+		case "limit":
+			if params.Limit, err = strconv.Atoi(value); err != nil || params.Limit <= 0 {
+				return nil, true, fmt.Errorf("limit:%s is not a positive number", value)
+			}
+
+		case "modified":
+			if _, isAge := inlineAge(value, now); isAge {
+				// A bare age means anything newer
+				value = ">=" + value
+			}
+			after, before, err := inlineRange(value, func(bound string) (time.Time, time.Time, error) {
+				return inlineDate(bound, now)
+			})
+			if err != nil {
+				return nil, true, fmt.Errorf("modified:%s: %w", value, err)
+			}
+			params.ModifiedAfter, params.ModifiedBefore = after, before
+
+		case "size":
+			min, max, err := inlineRange(value, func(bound string) (int64, int64, error) {
+				size, err := ParseSize(bound)
+				return size, size, err
+			})
+			if err != nil {
+				return nil, true, fmt.Errorf("size:%s: %w", value, err)
+			}
+			params.SizeMin, params.SizeMax = min, max
+		}
+	}
+
+	params.SemanticQuery = strings.Join(text, " ")
+	return params, found, nil
+}
+
+// inlineToken is a word of an inline query, or a quoted phrase.
+type inlineToken struct {
+	value  string
+	phrase bool
+}
+
+// splitInlineQuery splits query on whitespace, keeping double quoted phrases
+// together. Quotes within a word (path:"My Documents") stay part of it.
+func splitInlineQuery(query string) []inlineToken {
+	var tokens []inlineToken
+	var current strings.Builder
+	quoted, phrase := false, false
+
+	emit := func() {
+		if current.Len() > 0 || phrase {
+			if value := current.String(); !phrase || strings.TrimSpace(value) != "" {
+				tokens = append(tokens, inlineToken{value: value, phrase: phrase})
+			}
+		}
+		current.Reset()
+		phrase = false
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"' && !quoted && current.Len() == 0:
+			// A phrase of its own
+			quoted, phrase = true, true
+		case r == '"' && quoted && phrase:
+			quoted = false
+			emit()
+		case r == '"':
+			// Quoted value of a key, keep the quotes for ParseInlineQuery to trim
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			emit()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	emit()
+	return tokens
+}
+
+// inlineRange parses a comparison or range of values, returning the lower
+// and upper bound, nil when open. parse returns the first and last value a
+// bound stands for, e.g. the start and end of a day.
+func inlineRange[T any](value string, parse func(string) (T, T, error)) (*T, *T, error) {
+	if low, high, isRange := strings.Cut(value, ".."); isRange {
+		var lower, upper *T
+		if low != "" {
+			first, _, err := parse(low)
+			if err != nil {
+				return nil, nil, err
+			}
+			lower = &first
+		}
+		if high != "" {
+			_, last, err := parse(high)
+			if err != nil {
+				return nil, nil, err
+			}
+			upper = &last
+		}
+		return lower, upper, nil
+	}
+
+	for _, operator := range []string{">=", "<=", ">", "<"} {
+		bound, ok := strings.CutPrefix(value, operator)
+		if !ok {
+			continue
+		}
+		first, last, err := parse(bound)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch operator {
+		case ">":
+			return &last, nil, nil
+		case ">=":
+			return &first, nil, nil
+		case "<":
+			return nil, &first, nil
+		default: // <=
+			return nil, &last, nil
+		}
+	}
+
+	first, last, err := parse(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &first, &last, nil
+}
+
+// inlineDate parses a date (2025-01-31, 2025-01 or 2025) or a relative age
+// (7d, 2w, 3m, 1y) into the first and last instant it stands for.
+func inlineDate(value string, now time.Time) (time.Time, time.Time, error) {
+	if at, isAge := inlineAge(value, now); isAge {
+		return at, at, nil
+	}
+
+	if month, err := time.ParseInLocation("2006-01", value, now.Location()); err == nil {
+		return month, month.AddDate(0, 1, 0).Add(-time.Nanosecond), nil
+	}
+	if start, end, ok := parsePeriod(value, now); ok {
+		return start, end.Add(-time.Nanosecond), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD, YYYY-MM, YYYY or an age like 7d)", value)
+}
+
+// inlineAge parses a relative age like 7d, 2w, 3m or 1y into the time that long before now.
+func inlineAge(value string, now time.Time) (time.Time, bool) {
+	if len(value) < 2 {
+		return time.Time{}, false
+	}
+	count, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	units := map[byte]string{'d': "day", 'w': "week", 'm': "month", 'y': "year"}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return time.Time{}, false
+	}
+	return addUnits(now, unit, -count), true
+}
@@ -12,8 +12,31 @@ type File struct {
 	Size        int64  `gorm:"not null"`
 	ModTime     time.Time
 	Content     string `gorm:"type:text"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// Device and Inode identify the file on disk across renames, 0 where unknown
+	Device uint64 `gorm:"index:idx_files_identity"`
+	Inode  uint64 `gorm:"index:idx_files_identity"`
+	// EmbeddingModel and EmbeddingDimensions describe the stored vector
+	EmbeddingModel      string `gorm:"index"`
+	EmbeddingDimensions int
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+// Chunk is a piece of a file's content with its own embedding.
+type Chunk struct {
+	ID      uint `gorm:"primaryKey"`
+	FileID  uint `gorm:"index;not null"`
+	Ordinal int
+	// Byte offsets into File.Content, end exclusive
+	StartByte int
+	EndByte   int
+	// 1-based line range
+	StartLine int
+	EndLine   int
+	// 1-based PDF page, 0 for content without pages
+	Page        int
+	Text        string `gorm:"type:text"`
+	ContentHash string `gorm:"index"`
 }
 
 // Embedding represents a file's vector embedding.
@@ -23,3 +46,21 @@ type Embedding struct {
 	Vector []byte `gorm:"type:blob"`
 	File   File   `gorm:"foreignKey:FileID"`
 }
+
+// Setting is a key/value pair describing the state of the index.
+type Setting struct {
+	Key   string `gorm:"primaryKey"`
+	Value string
+}
+
+// EmbeddingCache maps a content hash and model to a previously computed vector,
+// so identical content at another path isn't embedded twice.
+type EmbeddingCache struct {
+	ContentHash string `gorm:"primaryKey"`
+	Model       string `gorm:"primaryKey"`
+	Dimensions  int
+	Vector      []byte `gorm:"type:blob"`
+	Hits        int64
+	CreatedAt   time.Time
+	LastUsedAt  time.Time `gorm:"index"`
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MoveFiles renames the file at from, or every file below from if it was a
+// directory, to the same place below to. Chunks and vectors stay with their
+// files, so nothing is embedded again. Files already indexed at a destination
+// were replaced on disk and are removed. It returns how many files moved.
+func MoveFiles(from, to string) (int, error) {
+	from = strings.TrimSuffix(from, "/")
+	to = strings.TrimSuffix(to, "/")
+	if from == to {
+		return 0, nil
+	}
+
+	moved := 0
+	err := Store.Transaction(func(tx *gorm.DB) error {
+		table, err := vectorTable(tx)
+		if err != nil {
+			return err
+		}
+
+		var batch []File
+		result := tx.Where(atOrBelow(from)).
+			FindInBatches(&batch, deleteBatchSize, func(batchTx *gorm.DB, _ int) error {
+				for idx := range batch {
+					file := &batch[idx]
+					// Never rewrite a path the query shouldn't have matched
+					if !strings.HasPrefix(file.Path, from) {
+						continue
+					}
+					target := to + strings.TrimPrefix(file.Path, from)
+
+					var replaced []File
+					if err := tx.Where("path = ?", target).Find(&replaced).Error; err != nil {
+						return err
+					}
+					if len(replaced) > 0 {
+						if err := deleteFileRows(tx, table, replaced); err != nil {
+							return err
+						}
+					}
+
+					// The path is part of the full text entry
+					if err := removeFullText(tx, file); err != nil {
+						return err
+					}
+					if err := tx.Model(file).Update("path", target).Error; err != nil {
+						return fmt.Errorf("failed to move %s: %w", file.Path, err)
+					}
+					file.Path = target
+					if err := indexFullText(tx, nil, file); err != nil {
+						return err
+					}
+					moved++
+				}
+				return nil
+			})
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// MoveCandidates returns the indexed files that may be the same file as one
+// found at a new path: those with its device and inode first, then those with
+// its content hash. An inode of 0 matches by content only.
+func MoveCandidates(device, inode uint64, contentHash string) ([]File, error) {
+	query := Store.Select("id", "path", "device", "inode", "content_hash")
+	if inode == 0 {
+		query = query.Where("content_hash = ?", contentHash)
+	} else {
+		query = query.Where("(device = ? AND inode = ?) OR content_hash = ?", device, inode, contentHash).
+			Order(clause.Expr{SQL: "device = ? AND inode = ? DESC", Vars: []any{device, inode}})
+	}
+
+	var files []File
+	if err := query.Order("id").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// SetFileIdentity records the device and inode of the file at path.
+func SetFileIdentity(path string, device, inode uint64) error {
+	return Store.Model(&File{}).
+		Where("path = ? AND (device != ? OR inode != ?)", path, device, inode).
+		Updates(map[string]any{"device": device, "inode": inode}).Error
+}
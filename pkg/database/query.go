@@ -3,8 +3,10 @@ package database
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"lamina/pkg/ai"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,11 +19,93 @@ type SearchParams struct {
 	SizeMin        *int64     `json:"size_min"`
 	SizeMax        *int64     `json:"size_max"`
 	PathContains   []string   `json:"path_contains"`
-	Limit          int        `json:"limit"`
+	PathExcludes   []string   `json:"path_excludes"`
+	// Phrases must occur verbatim (ignoring case) in the content
+	Phrases []string `json:"phrases"`
+	Limit   int      `json:"limit"`
+	// Mode picks hybrid, semantic or lexical ranking; it is never parsed from the query
+	Mode string `json:"-"`
+	// MinScore drops content matches scoring below it; it is never parsed from the query
+	MinScore float64 `json:"-"`
 }
 
-func ParseQuery(ctx context.Context, query string) (*SearchParams, error) {
-	response, err := ai.GenerateStructuredQuery(ctx, query)
+// Query parsers
+const (
+	ParserLLM          = "llm"
+	ParserRules        = "rules"
+	ParserRulesThenLLM = "rules-then-llm"
+)
+
+// ParseQuery turns a query into search parameters. Queries using the inline
+// filter syntax are parsed as such, others with the given parser: the
+// provider's generation model, fixed rules that need no network, or the rules
+// with the model only for queries they don't fully understand. Whenever the
+// model can't produce parameters, the rules' take is used rather than
+// dropping every filter.
+func ParseQuery(ctx context.Context, provider ai.ProviderFunc, query, parser string) (*SearchParams, error) {
+	now := time.Now()
+	inline, found, err := ParseInlineQuery(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	if found {
+		return inline, nil
+	}
+
+	params, err := parseNaturalQuery(ctx, provider, query, parser, now)
+	if err != nil {
+		return nil, err
+	}
+	// Quoted phrases stay exact in natural language queries too
+	params.Phrases = append(params.Phrases, inline.Phrases...)
+	return params, nil
+}
+
+// parseNaturalQuery parses a natural language query with parser.
+func parseNaturalQuery(ctx context.Context, provider ai.ProviderFunc, query, parser string, now time.Time) (*SearchParams, error) {
+	rules, complete := ParseQueryRules(query, now)
+
+	switch strings.ToLower(parser) {
+	case ParserRules:
+		return rules, nil
+
+	case ParserRulesThenLLM:
+		if complete {
+			return rules, nil
+		}
+		params, err := parseQueryLLM(ctx, provider, query)
+		if err != nil {
+			return rules, nil
+		}
+		return params, nil
+
+	case ParserLLM, "":
+		params, err := parseQueryLLM(ctx, provider, query)
+		if errors.Is(err, ai.ErrUnsupported) || errors.Is(err, errUnparsable) || errors.Is(err, errNoProvider) {
+			// Provider can't generate (e.g. offline), isn't set up or answered nonsense
+			return rules, nil
+		}
+		return params, err
+
+	default:
+		return nil, fmt.Errorf("invalid query parser %q (use %s, %s or %s)", parser, ParserLLM, ParserRules, ParserRulesThenLLM)
+	}
+}
+
+// errUnparsable is returned for generated parameters that aren't valid JSON.
+var errUnparsable = errors.New("generated search parameters are not valid JSON")
+
+// errNoProvider is returned when the provider to parse a query with can't be built.
+var errNoProvider = errors.New("no provider to parse the query")
+
+// parseQueryLLM has the provider's generation model parse query.
+func parseQueryLLM(ctx context.Context, provider ai.ProviderFunc, query string) (*SearchParams, error) {
+	model, err := provider()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoProvider, err)
+	}
+
+	response, err := ai.GenerateStructuredQuery(ctx, model, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse query: %w", err)
 	}
@@ -31,11 +115,7 @@ func ParseQuery(ctx context.Context, query string) (*SearchParams, error) {
 
 	var params SearchParams
 	if err := json.Unmarshal([]byte(jsonStr), &params); err != nil {
-		// Fallback: treat entire query as semantic search
-		return &SearchParams{
-			SemanticQuery: query,
-			Limit:         10,
-		}, nil
+		return nil, errUnparsable
 	}
 
 	// Set default limit if not specified
@@ -45,3 +125,37 @@ func ParseQuery(ctx context.Context, query string) (*SearchParams, error) {
 
 	return &params, nil
 }
+
+// sizeUnits are the multipliers of size suffixes, binary like formatFileSize.
+var sizeUnits = map[string]int64{
+	"":      1,
+	"b":     1,
+	"byte":  1,
+	"bytes": 1,
+	"k":     1 << 10,
+	"kb":    1 << 10,
+	"m":     1 << 20,
+	"mb":    1 << 20,
+	"g":     1 << 30,
+	"gb":    1 << 30,
+	"t":     1 << 40,
+	"tb":    1 << 40,
+}
+
+// ParseSize parses a human readable size such as "500", "5MB" or "1.5 gb" into bytes.
+func ParseSize(size string) (int64, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	split := strings.IndexFunc(size, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if split < 0 {
+		split = len(size)
+	}
+
+	number, err := strconv.ParseFloat(size[:split], 64)
+	unit, ok := sizeUnits[strings.TrimSpace(size[split:])]
+	if err != nil || !ok || number < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 500KB, 5MB or 1.5GB)", size)
+	}
+	return int64(number * float64(unit)), nil
+}
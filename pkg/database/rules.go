@@ -0,0 +1,348 @@
+package database
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileTypeRules map phrases naming kinds of files to their extensions, or to
+// the extension matched by submatch group when there are none. Words that are
+// also topics (python, go, ...) only count when followed by "files", "code"...
+var fileTypeRules = []struct {
+	pattern    *regexp.Regexp
+	extensions []string
+	group      int
+}{
+	// Explicit extensions like .pdf or *.go
+	{regexp.MustCompile(`(?i)(^|\s)\*?\.([a-z0-9]{1,5})\b`), nil, 2},
+	{regexp.MustCompile(`(?i)\bpdfs?\b(\s+(files?|documents?|docs))?`), []string{"pdf"}, 0},
+	{regexp.MustCompile(`(?i)\b(docx|word\s+(files?|documents?|docs))\b`), []string{"docx"}, 0},
+	{regexp.MustCompile(`(?i)\bmarkdown(\s+(files?|documents?|notes?))?\b`), []string{"md"}, 0},
+	{regexp.MustCompile(`(?i)\b(plain\s+)?text\s+files?\b`), []string{"txt"}, 0},
+	{regexp.MustCompile(`(?i)\b(go|golang)\s+(code|files?|source|programs?)\b`), []string{"go"}, 0},
+	{regexp.MustCompile(`(?i)\bpython\s+(code|files?|source|scripts?|programs?)\b`), []string{"py"}, 0},
+	{regexp.MustCompile(`(?i)\bjavascript\s+(code|files?|source)\b`), []string{"js"}, 0},
+	{regexp.MustCompile(`(?i)\btypescript\s+(code|files?|source)\b`), []string{"ts"}, 0},
+	{regexp.MustCompile(`(?i)\bcode\s+files?\b`), []string{"go", "py", "js", "ts"}, 0},
+	{regexp.MustCompile(`(?i)\b(shell|bash)\s+scripts?\b`), []string{"sh"}, 0},
+	{regexp.MustCompile(`(?i)\bsql\s+(files?|scripts?|queries)\b`), []string{"sql"}, 0},
+	{regexp.MustCompile(`(?i)\blog\s+files?\b`), []string{"log"}, 0},
+	{regexp.MustCompile(`(?i)\b(yaml|yml)\s+files?\b`), []string{"yaml", "yml"}, 0},
+	{regexp.MustCompile(`(?i)\b(json|xml|html|css)\s+files?\b`), nil, 1},
+	{regexp.MustCompile(`(?i)\b(images|pictures|photos)\b`), []string{"jpg", "png", "gif"}, 0},
+}
+
+const sizePattern = `(\d+(?:\.\d+)?)\s*(bytes?|b|kb|k|mb|m|gb|g|tb|t)\b`
+
+var (
+	sizeBetween = regexp.MustCompile(`(?i)\bbetween\s+` + sizePattern + `\s+(?:and|to)\s+` + sizePattern)
+	sizeAbove   = regexp.MustCompile(`(?i)(?:\b(?:larger|bigger|greater|more)\s+than|\bover|\babove|\bat\s+least|>=?)\s*` + sizePattern)
+	sizeBelow   = regexp.MustCompile(`(?i)(?:\b(?:smaller|less)\s+than|\bunder|\bbelow|\bat\s+most|<=?)\s*` + sizePattern)
+)
+
+const datePattern = `(\d{4}-\d{1,2}-\d{1,2}|(?:january|february|march|april|may|june|july|august|september|october|november|december|jan|feb|mar|apr|jun|jul|aug|sept|sep|oct|nov|dec)\.?(?:\s+\d{1,2}(?:st|nd|rd|th)?)?(?:,?\s+\d{4})?|\d{4})\b`
+
+var (
+	dateRelative = regexp.MustCompile(`(?i)\b(?:(?:in|during|within|from)\s+the\s+)?(?:last|past)\s+(?:(\d+)\s+)?(day|week|month|year)s?\b`)
+	dateAgo      = regexp.MustCompile(`(?i)\b(\d+|a|an|one)\s+(day|week|month|year)s?\s+ago\b`)
+	dateNamed    = regexp.MustCompile(`(?i)\b(today|yesterday|this\s+(?:week|month|year))\b`)
+	dateSince    = regexp.MustCompile(`(?i)\b(since|after|from|before|until|till|in|during|on)\s+` + datePattern)
+)
+
+var (
+	pathFolder = regexp.MustCompile(`(?i)\b(?:in|under|inside|within|from)\s+(?:the\s+|my\s+)?(?:(?:folder|directory|dir)\s+([\w.~/-]+)|([\w.~/-]+)\s+(?:folder|directory|dir)\b)`)
+	// Bare paths must start like one, so prose such as and/or or TCP/IP isn't
+	pathToken = regexp.MustCompile(`(?:^|\s)((?:~|\.{1,2})?/[\w./-]*)`)
+)
+
+var limitPattern = regexp.MustCompile(`(?i)\b(?:top|first|show(?:\s+me)?)\s+(\d+)\b|\b(\d+)\s+(?:results|matches)\b`)
+
+var monthNames = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// fillerPhrases are multi-word fillers, see fillerWords.
+var fillerPhrases = regexp.MustCompile(`(?i)\b(?:dealing\s+with|having\s+to\s+do\s+with|related\s+to|that\s+mention)\b`)
+
+// fillerWords carry no content once the filters have been taken out of a query.
+var fillerWords = map[string]bool{
+	"find": true, "show": true, "me": true, "my": true, "all": true, "list": true,
+	"search": true, "files": true, "file": true, "documents": true, "document": true,
+	"docs": true, "that": true, "which": true, "are": true, "were": true, "was": true,
+	"is": true, "modified": true, "created": true, "changed": true, "updated": true,
+	"edited": true, "containing": true, "contain": true, "contains": true, "about": true,
+	"regarding": true, "mentioning": true, "dealing": true, "related": true,
+}
+
+// connectorWords are dropped from the ends of what remains of a query.
+var connectorWords = map[string]bool{
+	"and": true, "or": true, "from": true, "in": true, "the": true, "of": true,
+	"on": true, "at": true, "for": true, "with": true, "to": true, "than": true,
+	"under": true, "inside": true, "within": true,
+	"a": true, "an": true, "any": true, "some": true,
+}
+
+// vagueWords hint at filters the rules couldn't pin down.
+var vagueWords = map[string]bool{
+	"recent": true, "recently": true, "old": true, "older": true, "newer": true,
+	"newest": true, "latest": true, "oldest": true, "large": true, "larger": true,
+	"big": true, "bigger": true, "huge": true, "small": true, "smaller": true,
+	"tiny": true, "ago": true, "since": true, "before": true, "after": true,
+	"between": true, "week": true, "month": true, "year": true, "yesterday": true,
+	"folder": true, "directory": true, "kb": true, "mb": true, "gb": true,
+}
+
+// ParseQueryRules extracts file types, dates, sizes, paths and a result limit
+// from a natural language query with fixed rules, resolving relative dates
+// against now. What's left becomes the semantic query. complete is false when
+// the query seems to describe filters the rules didn't understand.
+func ParseQueryRules(query string, now time.Time) (params *SearchParams, complete bool) {
+	params = &SearchParams{}
+	text := " " + query + " "
+
+	// Sizes go first so "more than 5MB" isn't read as anything else
+	text = consume(text, sizeBetween, func(m []string) bool {
+		low, lowErr := ParseSize(m[1] + m[2])
+		high, highErr := ParseSize(m[3] + m[4])
+		if lowErr != nil || highErr != nil {
+			return false
+		}
+		params.SizeMin, params.SizeMax = &low, &high
+		return true
+	})
+	text = consume(text, sizeAbove, func(m []string) bool {
+		size, err := ParseSize(m[1] + m[2])
+		if err != nil {
+			return false
+		}
+		params.SizeMin = &size
+		return true
+	})
+	text = consume(text, sizeBelow, func(m []string) bool {
+		size, err := ParseSize(m[1] + m[2])
+		if err != nil {
+			return false
+		}
+		params.SizeMax = &size
+		return true
+	})
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	setRange := func(after, before time.Time) {
+		params.ModifiedAfter = &after
+		if !before.IsZero() {
+			params.ModifiedBefore = &before
+		}
+	}
+
+	text = consume(text, dateRelative, func(m []string) bool {
+		count := 1
+		if m[1] != "" {
+			count, _ = strconv.Atoi(m[1])
+		}
+		setRange(addUnits(now, strings.ToLower(m[2]), -count), time.Time{})
+		return true
+	})
+	text = consume(text, dateAgo, func(m []string) bool {
+		count, err := strconv.Atoi(m[1])
+		if err != nil {
+			count = 1 // a, an, one
+		}
+		unit := strings.ToLower(m[2])
+		start := addUnits(today, unit, -count)
+		setRange(start, addUnits(start, unit, 1))
+		return true
+	})
+	text = consume(text, dateNamed, func(m []string) bool {
+		switch strings.Join(strings.Fields(strings.ToLower(m[1])), " ") {
+		case "today":
+			setRange(today, time.Time{})
+		case "yesterday":
+			setRange(today.AddDate(0, 0, -1), today)
+		case "this week":
+			// Weeks start on Monday
+			setRange(today.AddDate(0, 0, -(int(today.Weekday())+6)%7), time.Time{})
+		case "this month":
+			setRange(today.AddDate(0, 0, 1-today.Day()), time.Time{})
+		case "this year":
+			setRange(time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, now.Location()), time.Time{})
+		}
+		return true
+	})
+	text = consume(text, dateSince, func(m []string) bool {
+		start, end, ok := parsePeriod(m[2], now)
+		if !ok {
+			return false
+		}
+		switch strings.ToLower(m[1]) {
+		case "since":
+			params.ModifiedAfter = &start
+		case "after":
+			params.ModifiedAfter = &end
+		case "before", "until", "till":
+			params.ModifiedBefore = &start
+		default: // from, in, during, on
+			setRange(start, end)
+		}
+		return true
+	})
+
+	for _, rule := range fileTypeRules {
+		text = consume(text, rule.pattern, func(m []string) bool {
+			extensions := rule.extensions
+			if extensions == nil {
+				extensions = []string{strings.ToLower(m[rule.group])}
+			}
+			for _, extension := range extensions {
+				if !containsFold(params.FileTypes, extension) {
+					params.FileTypes = append(params.FileTypes, extension)
+				}
+			}
+			return true
+		})
+	}
+
+	home, _ := os.UserHomeDir()
+	addPath := func(path string) bool {
+		path = strings.TrimSuffix(path, "/")
+		if strings.HasPrefix(path, "~") && home != "" {
+			path = home + strings.TrimPrefix(path, "~")
+		}
+		if path == "" || path == "." {
+			return false
+		}
+		params.PathContains = append(params.PathContains, path)
+		return true
+	}
+	text = consume(text, pathFolder, func(m []string) bool {
+		return addPath(m[1] + m[2])
+	})
+	text = consume(text, pathToken, func(m []string) bool {
+		return addPath(m[1])
+	})
+
+	text = consume(text, limitPattern, func(m []string) bool {
+		params.Limit, _ = strconv.Atoi(m[1] + m[2])
+		return params.Limit > 0
+	})
+	if params.Limit == 0 {
+		params.Limit = 10
+	}
+
+	// What's left is the content to search for, minus the glue words
+	text = consume(text, fillerPhrases, func([]string) bool { return true })
+	complete = true
+	var words []string
+	for _, word := range strings.Fields(text) {
+		lower := strings.ToLower(strings.Trim(word, ",.;:!?"))
+		if vagueWords[lower] {
+			complete = false
+		}
+		if lower != "" && !fillerWords[lower] && !vagueWords[lower] {
+			words = append(words, strings.Trim(word, ",;:!?"))
+		}
+	}
+	for len(words) > 0 && connectorWords[strings.ToLower(words[0])] {
+		words = words[1:]
+	}
+	for len(words) > 0 && connectorWords[strings.ToLower(words[len(words)-1])] {
+		words = words[:len(words)-1]
+	}
+	params.SemanticQuery = strings.Join(words, " ")
+	return params, complete
+}
+
+// consume calls apply for every match of pattern in text and blanks out the
+// matches apply accepts.
+func consume(text string, pattern *regexp.Regexp, apply func(match []string) bool) string {
+	return pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if !apply(pattern.FindStringSubmatch(match)) {
+			return match
+		}
+		return " "
+	})
+}
+
+// addUnits moves t by count days, weeks, months or years.
+func addUnits(t time.Time, unit string, count int) time.Time {
+	switch unit {
+	case "week":
+		return t.AddDate(0, 0, 7*count)
+	case "month":
+		return t.AddDate(0, count, 0)
+	case "year":
+		return t.AddDate(count, 0, 0)
+	default:
+		return t.AddDate(0, 0, count)
+	}
+}
+
+// parsePeriod parses an absolute date as the day, month or year it names,
+// returning its start and the start of the following period. A month without
+// a year is its latest occurrence up to now.
+func parsePeriod(value string, now time.Time) (start, end time.Time, ok bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	location := now.Location()
+
+	if date, err := time.ParseInLocation("2006-1-2", value, location); err == nil {
+		return date, date.AddDate(0, 0, 1), true
+	}
+
+	if len(value) == 4 {
+		year, err := strconv.Atoi(value)
+		if err != nil || year < 1970 || year > 2100 {
+			return start, end, false
+		}
+		start = time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+		return start, start.AddDate(1, 0, 0), true
+	}
+
+	fields := strings.Fields(strings.NewReplacer(",", " ", ".", " ").Replace(value))
+	if len(fields) == 0 || len(fields[0]) < 3 {
+		return start, end, false
+	}
+	month, known := monthNames[fields[0][:3]]
+	if !known {
+		return start, end, false
+	}
+
+	day, year := 0, 0
+	for _, field := range fields[1:] {
+		number, err := strconv.Atoi(strings.TrimRight(field, "stndrh"))
+		if err != nil {
+			return start, end, false
+		}
+		if len(field) == 4 {
+			year = number
+		} else {
+			day = number
+		}
+	}
+	if year == 0 {
+		year = now.Year()
+		if month > now.Month() {
+			year--
+		}
+	}
+
+	if day == 0 {
+		start = time.Date(year, month, 1, 0, 0, 0, 0, location)
+		return start, start.AddDate(0, 1, 0), true
+	}
+	start = time.Date(year, month, day, 0, 0, 0, 0, location)
+	return start, start.AddDate(0, 0, 1), true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
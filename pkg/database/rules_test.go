@@ -0,0 +1,213 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// rulesNow is a Wednesday, the reference time of the ParseQueryRules tests.
+var rulesNow = time.Date(2026, time.March, 18, 15, 30, 0, 0, time.UTC)
+
+func date(year int, month time.Month, day int) *time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func size(bytes int64) *int64 {
+	return &bytes
+}
+
+func TestParseQueryRules(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastWeek := rulesNow.AddDate(0, 0, -7)
+	lastThreeMonths := rulesNow.AddDate(0, -3, 0)
+
+	tests := []struct {
+		query string
+		want  SearchParams
+	}{
+		{
+			query: "pdfs larger than 1MB modified last week",
+			want: SearchParams{
+				FileTypes:     []string{"pdf"},
+				SizeMin:       size(1 << 20),
+				ModifiedAfter: &lastWeek,
+			},
+		},
+
+		// Relative dates
+		{
+			query: "meeting notes from the last 3 months",
+			want:  SearchParams{SemanticQuery: "meeting notes", ModifiedAfter: &lastThreeMonths},
+		},
+		{
+			query: "invoices from 2 weeks ago",
+			want:  SearchParams{SemanticQuery: "invoices", ModifiedAfter: date(2026, time.March, 4), ModifiedBefore: date(2026, time.March, 11)},
+		},
+		{
+			query: "notes changed yesterday",
+			want:  SearchParams{SemanticQuery: "notes", ModifiedAfter: date(2026, time.March, 17), ModifiedBefore: date(2026, time.March, 18)},
+		},
+		{
+			query: "todo lists edited today",
+			want:  SearchParams{SemanticQuery: "todo lists", ModifiedAfter: date(2026, time.March, 18)},
+		},
+		{
+			query: "drafts this week",
+			want:  SearchParams{SemanticQuery: "drafts", ModifiedAfter: date(2026, time.March, 16)},
+		},
+		{
+			query: "expenses this month",
+			want:  SearchParams{SemanticQuery: "expenses", ModifiedAfter: date(2026, time.March, 1)},
+		},
+
+		// Absolute dates
+		{
+			query: "reports since 2025-11-03",
+			want:  SearchParams{SemanticQuery: "reports", ModifiedAfter: date(2025, time.November, 3)},
+		},
+		{
+			query: "contracts before 2024",
+			want:  SearchParams{SemanticQuery: "contracts", ModifiedBefore: date(2024, time.January, 1)},
+		},
+		{
+			query: "minutes after March 3, 2025",
+			want:  SearchParams{SemanticQuery: "minutes", ModifiedAfter: date(2025, time.March, 4)},
+		},
+		{
+			query: "letters in 2023",
+			want:  SearchParams{SemanticQuery: "letters", ModifiedAfter: date(2023, time.January, 1), ModifiedBefore: date(2024, time.January, 1)},
+		},
+		{
+			query: "budget on jan 5th 2026",
+			want:  SearchParams{SemanticQuery: "budget", ModifiedAfter: date(2026, time.January, 5), ModifiedBefore: date(2026, time.January, 6)},
+		},
+
+		// A month without a year is its latest occurrence up to now
+		{
+			query: "taxes in march",
+			want:  SearchParams{SemanticQuery: "taxes", ModifiedAfter: date(2026, time.March, 1), ModifiedBefore: date(2026, time.April, 1)},
+		},
+		{
+			query: "holiday plans in december",
+			want:  SearchParams{SemanticQuery: "holiday plans", ModifiedAfter: date(2025, time.December, 1), ModifiedBefore: date(2026, time.January, 1)},
+		},
+		{
+			query: "receipts since Nov 20",
+			want:  SearchParams{SemanticQuery: "receipts", ModifiedAfter: date(2025, time.November, 20)},
+		},
+
+		// Sizes
+		{
+			query: "videos over 1.5GB",
+			want:  SearchParams{SemanticQuery: "videos", SizeMin: size(1536 << 20)},
+		},
+		{
+			query: "config smaller than 10kb",
+			want:  SearchParams{SemanticQuery: "config", SizeMax: size(10 << 10)},
+		},
+		{
+			query: "scans between 2MB and 5MB",
+			want:  SearchParams{SemanticQuery: "scans", SizeMin: size(2 << 20), SizeMax: size(5 << 20)},
+		},
+		{
+			query: "logs at least 500 bytes",
+			want:  SearchParams{SemanticQuery: "logs", SizeMin: size(500)},
+		},
+
+		// File types, paths and limits
+		{
+			query: "python scripts about scraping",
+			want:  SearchParams{SemanticQuery: "scraping", FileTypes: []string{"py"}},
+		},
+		{
+			query: "top 3 markdown notes under ~/projects/lamina",
+			want:  SearchParams{FileTypes: []string{"md"}, PathContains: []string{filepath.Join(home, "projects/lamina")}, Limit: 3},
+		},
+		{
+			query: "ideas in the folder drafts",
+			want:  SearchParams{SemanticQuery: "ideas", PathContains: []string{"drafts"}},
+		},
+		{
+			query: "recipes in ./cooking/",
+			want:  SearchParams{SemanticQuery: "recipes", PathContains: []string{"./cooking"}},
+		},
+		{
+			query: "backups /var/backups",
+			want:  SearchParams{SemanticQuery: "backups", PathContains: []string{"/var/backups"}},
+		},
+
+		// Slashes in prose aren't paths
+		{
+			query: "notes on TCP/IP and/or UDP",
+			want:  SearchParams{SemanticQuery: "notes on TCP/IP and/or UDP"},
+		},
+		{
+			query: "input/output handling",
+			want:  SearchParams{SemanticQuery: "input/output handling"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			if test.want.Limit == 0 {
+				test.want.Limit = 10
+			}
+
+			params, complete := ParseQueryRules(test.query, rulesNow)
+			if !reflect.DeepEqual(*params, test.want) {
+				t.Errorf("ParseQueryRules(%q) =\n%s\nwant\n%s", test.query, describeParams(params), describeParams(&test.want))
+			}
+			if !complete {
+				t.Errorf("ParseQueryRules(%q) is incomplete", test.query)
+			}
+		})
+	}
+}
+
+func TestParseQueryRulesIncomplete(t *testing.T) {
+	// Filters the rules can't pin down must leave the query to the LLM
+	for _, query := range []string{
+		"recent invoices",
+		"big videos",
+		"notes from a while ago",
+		"the latest meeting minutes",
+		"files older than my thesis",
+		"reports before the deadline",
+		"photos larger than the others",
+		"small text snippets",
+	} {
+		t.Run(query, func(t *testing.T) {
+			if params, complete := ParseQueryRules(query, rulesNow); complete {
+				t.Errorf("ParseQueryRules(%q) is complete: %s", query, describeParams(params))
+			}
+		})
+	}
+}
+
+// describeParams formats params with dates and sizes dereferenced.
+func describeParams(params *SearchParams) string {
+	deref := func(value any) any {
+		switch v := value.(type) {
+		case *time.Time:
+			if v != nil {
+				return v.Format(time.RFC3339)
+			}
+		case *int64:
+			if v != nil {
+				return *v
+			}
+		}
+		return nil
+	}
+	return fmt.Sprintf("  semantic=%q types=%v after=%v before=%v min=%v max=%v paths=%q limit=%d",
+		params.SemanticQuery, params.FileTypes, deref(params.ModifiedAfter), deref(params.ModifiedBefore),
+		deref(params.SizeMin), deref(params.SizeMax), params.PathContains, params.Limit)
+}
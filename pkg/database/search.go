@@ -4,98 +4,450 @@ import (
 	"context"
 	"fmt"
 	"lamina/pkg/ai"
+	"math"
+	"sort"
 	"strings"
 
-	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+	"gorm.io/gorm"
 )
 
-// AdvancedSearchFiles performs search with parsed parameters
-func AdvancedSearchFiles(ctx context.Context, params *SearchParams) ([]File, error) {
+// Search modes
+const (
+	ModeHybrid   = "hybrid"
+	ModeSemantic = "semantic"
+	ModeLexical  = "lexical"
+)
+
+const (
+	// rrfK dampens the weight of top ranks in reciprocal rank fusion.
+	rrfK = 60
+
+	// vecMaxK is the most neighbours a vec0 KNN query returns.
+	vecMaxK = 4096
+
+	// exactScanLimit is the most filtered chunks compared one by one instead of via KNN.
+	exactScanLimit = 100000
+)
+
+// SearchResult is a matching file along with the chunk that matched best.
+type SearchResult struct {
+	File File
+	// Chunk is the best matching chunk, nil for searches without semantic content
+	Chunk *Chunk
+	// Distance is the L2 distance between Chunk and the query embedding,
+	// nil when the query wasn't embedded (lexical mode, metadata-only searches)
+	Distance *float64
+	// Score rates the match from 0 (unrelated) to 1 (identical). It is the
+	// vector similarity when Distance is set, else BM25 relative to the best hit.
+	Score float64
+}
+
+// chunkHit is a chunk found by the vector search.
+type chunkHit struct {
+	ChunkID  uint
+	FileID   uint
+	Distance float64
+}
+
+// AdvancedSearchFiles performs search with parsed parameters. Content queries
+// run a vector search, a BM25 search or both fused with reciprocal rank
+// fusion, depending on params.Mode.
+func AdvancedSearchFiles(ctx context.Context, provider ai.ProviderFunc, params *SearchParams) ([]SearchResult, error) {
 	var files []File
 
-	// Start with base query
-	query := Store.Model(&File{})
+	query := filterFiles(Store.Model(&File{}), params)
+
+	if params.SemanticQuery == "" {
+		// No content to search, just order by modification time
+		if err := query.Order("mod_time DESC").Limit(params.Limit).Find(&files).Error; err != nil {
+			return nil, err
+		}
+
+		results := make([]SearchResult, len(files))
+		for i, file := range files {
+			results[i] = SearchResult{File: file}
+		}
+		return results, nil
+	}
+
+	mode := strings.ToLower(params.Mode)
+	if mode == "" {
+		mode = ModeHybrid
+	}
+	if mode != ModeHybrid && mode != ModeSemantic && mode != ModeLexical {
+		return nil, fmt.Errorf("invalid search mode %q (use %s, %s or %s)", params.Mode, ModeHybrid, ModeSemantic, ModeLexical)
+	}
+
+	// Fusion can promote files ranked low by one side, so rank more than needed
+	candidateLimit := params.Limit
+	if mode == ModeHybrid {
+		candidateLimit *= 3
+	}
+
+	var rankings [][]uint
+	var queryBlob []byte
+	bestChunk := map[uint]uint{}
+	lexicalScores := map[uint]float64{}
+
+	if mode != ModeLexical {
+		// Lexical searches get by without a provider
+		embedder, err := provider()
+		if err != nil {
+			return nil, fmt.Errorf("semantic search needs a provider: %w", err)
+		}
+		if queryBlob, err = embedQuery(ctx, embedder, params.SemanticQuery); err != nil {
+			return nil, err
+		}
+
+		hits, err := semanticSearch(queryBlob, params, candidateLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		ranking := make([]uint, len(hits))
+		for i, hit := range hits {
+			bestChunk[hit.FileID] = hit.ChunkID
+			ranking[i] = hit.FileID
+		}
+		rankings = append(rankings, ranking)
+	}
+
+	// Hybrid quietly degrades to semantic-only without FTS5
+	if mode == ModeLexical || (mode == ModeHybrid && lexicalAvailable) {
+		hits, err := lexicalSearch(params, candidateLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		ranking := make([]uint, len(hits))
+		for i, hit := range hits {
+			ranking[i] = hit.FileID
+			// bm25() is negative, so the best hit scores 1
+			if hits[0].Score != 0 {
+				lexicalScores[hit.FileID] = hit.Score / hits[0].Score
+			}
+		}
+		rankings = append(rankings, ranking)
+	}
+
+	fileIDs := fuseRankings(rankings...)
+	if len(fileIDs) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	query = query.Where("id IN ?", fileIDs)
+	// Order by the fused relevance (maintain ranking order)
+	orderCases := make([]string, len(fileIDs))
+	for i, id := range fileIDs {
+		orderCases[i] = fmt.Sprintf("WHEN %d THEN %d", id, i)
+	}
+	query = query.Order(fmt.Sprintf("CASE id %s END", strings.Join(orderCases, " ")))
+
+	// Every candidate is loaded, as fused ranks don't follow scores and
+	// MinScore has to apply before the limit
+	if err := query.Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	// Files only found lexically get the chunk with the most query terms
+	var lexicalOnly []uint
+	for _, file := range files {
+		if _, ok := bestChunk[file.ID]; !ok {
+			lexicalOnly = append(lexicalOnly, file.ID)
+		}
+	}
+	lexicalChunks, err := bestLexicalChunks(lexicalOnly, params.SemanticQuery)
+	if err != nil {
+		return nil, err
+	}
+	for fileID, chunkID := range lexicalChunks {
+		bestChunk[fileID] = chunkID
+	}
+
+	chunkIDs := make([]uint, 0, len(files))
+	for _, file := range files {
+		chunkIDs = append(chunkIDs, bestChunk[file.ID])
+	}
+	chunks, err := ChunksByID(chunkIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lexical hits are scored by vectors too whenever the query was embedded
+	var distances map[uint]float64
+	if queryBlob != nil {
+		if distances, err = chunkDistances(queryBlob, chunkIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]SearchResult, 0, min(len(files), params.Limit))
+	for _, file := range files {
+		if len(results) == params.Limit {
+			break
+		}
+
+		result := SearchResult{File: file, Score: lexicalScores[file.ID]}
+		if chunk, ok := chunks[bestChunk[file.ID]]; ok {
+			result.Chunk = &chunk
+			if distance, ok := distances[chunk.ID]; ok {
+				result.Distance = &distance
+				result.Score = SimilarityScore(distance)
+			}
+		}
+
+		if result.Score < params.MinScore {
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SimilarityScore maps the L2 distance between two unit vectors, as
+// EncodeVector stores them, to their cosine similarity rescaled from [-1, 1]
+// to [0, 1].
+func SimilarityScore(distance float64) float64 {
+	return math.Max(0, math.Min(1, 1-distance*distance/4))
+}
 
-	// Apply metadata filters first
+// filterFiles applies the metadata filters of params to query, which must
+// have the files table in scope.
+func filterFiles(query *gorm.DB, params *SearchParams) *gorm.DB {
 	if len(params.FileTypes) > 0 {
 		// Convert file types to LIKE conditions for file extensions
 		var conditions []string
 		var args []interface{}
 		for _, ext := range params.FileTypes {
-			conditions = append(conditions, "path LIKE ?")
-			args = append(args, "%."+ext)
+			conditions = append(conditions, `files.path LIKE ? ESCAPE '\'`)
+			args = append(args, "%."+escapeLike(ext))
 		}
-		query = query.Where(strings.Join(conditions, " OR "), args...)
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
 	}
 
 	if params.ModifiedAfter != nil {
-		query = query.Where("mod_time >= ?", *params.ModifiedAfter)
+		query = query.Where("files.mod_time >= ?", *params.ModifiedAfter)
 	}
 
 	if params.ModifiedBefore != nil {
-		query = query.Where("mod_time <= ?", *params.ModifiedBefore)
+		query = query.Where("files.mod_time <= ?", *params.ModifiedBefore)
 	}
 
 	if params.SizeMin != nil {
-		query = query.Where("size >= ?", *params.SizeMin)
+		query = query.Where("files.size >= ?", *params.SizeMin)
 	}
 
 	if params.SizeMax != nil {
-		query = query.Where("size <= ?", *params.SizeMax)
+		query = query.Where("files.size <= ?", *params.SizeMax)
 	}
 
-	if len(params.PathContains) > 0 {
-		for _, pathPart := range params.PathContains {
-			query = query.Where("path LIKE ?", "%"+pathPart+"%")
-		}
+	// Paths often hold _ and %, which must match literally
+	for _, pathPart := range params.PathContains {
+		query = query.Where(`files.path LIKE ? ESCAPE '\'`, "%"+escapeLike(pathPart)+"%")
 	}
 
-	// If we have semantic query, do vector search first then filter
-	if params.SemanticQuery != "" {
-		// Generate embedding for semantic query
-		queryEmbedding, err := ai.GenerateQueryEmbedding(ctx, params.SemanticQuery)
-		if err != nil {
+	for _, pathPart := range params.PathExcludes {
+		query = query.Where(`files.path NOT LIKE ? ESCAPE '\'`, "%"+escapeLike(pathPart)+"%")
+	}
+
+	for _, phrase := range params.Phrases {
+		query = query.Where(`files.content LIKE ? ESCAPE '\'`, "%"+escapeLike(phrase)+"%")
+	}
+	return query
+}
+
+// escapeLike escapes the LIKE wildcards in value so it matches literally.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// hasFilters reports whether params restricts which files match.
+func hasFilters(params *SearchParams) bool {
+	return len(params.FileTypes) > 0 || params.ModifiedAfter != nil || params.ModifiedBefore != nil ||
+		params.SizeMin != nil || params.SizeMax != nil || len(params.PathContains) > 0 ||
+		len(params.PathExcludes) > 0 || len(params.Phrases) > 0
+}
+
+// embedQuery embeds a search query into a serialized vector, refusing to
+// compare against vectors indexed with a different model.
+func embedQuery(ctx context.Context, provider ai.Provider, text string) ([]byte, error) {
+	// Only compare against vectors produced by the same model
+	if err := CheckEmbeddingModel(provider.EmbeddingModel(), provider.Dimensions()); err != nil {
+		return nil, err
+	}
+
+	queryEmbedding, err := provider.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeVector(queryEmbedding)
+}
+
+// semanticSearch returns the best matching chunk of up to limit files that
+// pass the filters of params, best first. Filters are applied inside the
+// nearest neighbour search, so narrow filters still find every match: small
+// filtered sets are scanned exactly, larger ones by widening the KNN search.
+func semanticSearch(queryBlob []byte, params *SearchParams, limit int) ([]chunkHit, error) {
+	table, err := VectorTable()
+	if err != nil {
+		return nil, err
+	}
+
+	// Without filters the KNN search alone tells when every chunk was seen
+	if !hasFilters(params) {
+		return widenedSearch(table, queryBlob, params, limit, math.MaxInt)
+	}
+
+	var candidates int64
+	counted := Store.Table("chunks").Joins("JOIN files ON files.id = chunks.file_id")
+	if err := filterFiles(counted, params).Count(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	if candidates <= exactScanLimit {
+		return exactSearch(table, queryBlob, params, limit)
+	}
+	return widenedSearch(table, queryBlob, params, limit, int(candidates))
+}
+
+// chunkDistances returns the distance of each of the chunks to the query,
+// keyed by chunk ID. Chunks without a vector are left out.
+func chunkDistances(queryBlob []byte, chunkIDs []uint) (map[uint]float64, error) {
+	table, err := VectorTable()
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []chunkHit
+	err = Store.Table(table).
+		Select("chunk_id, vec_distance_l2(embedding, ?) AS distance", queryBlob).
+		Where("chunk_id IN ?", chunkIDs).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	distances := make(map[uint]float64, len(hits))
+	for _, hit := range hits {
+		distances[hit.ChunkID] = hit.Distance
+	}
+	return distances, nil
+}
+
+// exactSearch computes the distance to every chunk of the filtered files.
+func exactSearch(table string, queryBlob []byte, params *SearchParams, limit int) ([]chunkHit, error) {
+	query := Store.Table("chunks").
+		Select("chunks.id AS chunk_id, chunks.file_id, MIN(vec_distance_l2(v.embedding, ?)) AS distance", queryBlob).
+		Joins("JOIN files ON files.id = chunks.file_id").
+		Joins(fmt.Sprintf("JOIN %s v ON v.chunk_id = chunks.id", table))
+
+	// SQLite returns the chunk_id of the row holding the MIN
+	var hits []chunkHit
+	err := filterFiles(query, params).
+		Group("chunks.file_id").
+		Order("distance").
+		Limit(limit).
+		Scan(&hits).Error
+	return hits, err
+}
+
+// widenedSearch runs the KNN search with growing k until it yields limit
+// files passing the filters, or every candidate chunk has been considered.
+func widenedSearch(table string, queryBlob []byte, params *SearchParams, limit, candidates int) ([]chunkHit, error) {
+	k := min(limit*10, vecMaxK)
+	for {
+		query := Store.Table(fmt.Sprintf(`(
+			SELECT chunk_id, distance FROM %s
+			WHERE embedding MATCH ? AND k = ?
+		) v`, table), queryBlob, k).
+			Select("v.chunk_id, chunks.file_id, v.distance").
+			Joins("JOIN chunks ON chunks.id = v.chunk_id").
+			Joins("JOIN files ON files.id = chunks.file_id").
+			Order("v.distance")
+
+		var chunkHits []chunkHit
+		if err := filterFiles(query, params).Scan(&chunkHits).Error; err != nil {
 			return nil, err
 		}
 
-		// Serialize query embedding
-		queryBlob, err := sqlite_vec.SerializeFloat32(queryEmbedding)
-		if err != nil {
-			return nil, err
+		// Aggregate chunk hits back to files, keeping each file's best chunk
+		seen := map[uint]bool{}
+		var hits []chunkHit
+		for _, hit := range chunkHits {
+			if !seen[hit.FileID] {
+				seen[hit.FileID] = true
+				hits = append(hits, hit)
+			}
 		}
 
-		// Get candidate file IDs from vector search (larger limit for filtering)
-		var fileIDs []uint
-		vectorLimit := params.Limit * 10 // Get more candidates to filter
-		err = Store.Raw(`
-			SELECT file_id FROM vec_embeddings 
-			WHERE embedding MATCH ? 
-			ORDER BY distance 
-			LIMIT ?
-		`, queryBlob, vectorLimit).Scan(&fileIDs).Error
-		if err != nil {
-			return nil, err
+		// Unfiltered, fewer neighbours than asked for are all there are
+		exhausted := k >= candidates || (!hasFilters(params) && len(chunkHits) < k)
+		if len(hits) >= limit || exhausted {
+			return hits[:min(limit, len(hits))], nil
 		}
+		if k >= vecMaxK {
+			// vec0 can't return more neighbours; scan instead of returning too few
+			if hasFilters(params) {
+				return exactSearch(table, queryBlob, params, limit)
+			}
+			return hits, nil
+		}
+		k = min(k*4, vecMaxK)
+	}
+}
+
+// fuseRankings merges rankings of file IDs with reciprocal rank fusion: each
+// file scores the sum of 1/(rrfK + rank) over the rankings it appears in.
+func fuseRankings(rankings ...[]uint) []uint {
+	if len(rankings) == 1 {
+		return rankings[0]
+	}
 
-		if len(fileIDs) > 0 {
-			query = query.Where("id IN ?", fileIDs)
-			// Order by the vector search relevance (maintain original order)
-			orderCases := make([]string, len(fileIDs))
-			for i, id := range fileIDs {
-				orderCases[i] = fmt.Sprintf("WHEN %d THEN %d", id, i)
+	scores := map[uint]float64{}
+	var order []uint
+	for _, ranking := range rankings {
+		for rank, id := range ranking {
+			if _, seen := scores[id]; !seen {
+				order = append(order, id)
 			}
-			query = query.Order(fmt.Sprintf("CASE id %s END", strings.Join(orderCases, " ")))
-		} else {
-			// No vector matches, return empty result
-			return []File{}, nil
+			scores[id] += 1 / float64(rrfK+rank+1)
 		}
-	} else {
-		// No semantic search, just order by modification time
-		query = query.Order("mod_time DESC")
 	}
 
-	err := query.Limit(params.Limit).Find(&files).Error
-	return files, err
+	// Stable so ties keep the order of the first ranking
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	return order
+}
+
+// bestLexicalChunks finds the chunk of each file containing the most query
+// terms, keyed by file ID. Files without any are left out.
+func bestLexicalChunks(fileIDs []uint, text string) (map[uint]uint, error) {
+	best := map[uint]uint{}
+	if len(fileIDs) == 0 {
+		return best, nil
+	}
+
+	var chunks []Chunk
+	if err := Store.Where("file_id IN ?", fileIDs).Order("file_id, ordinal").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+
+	terms := queryTerms(strings.ToLower(text))
+	bestCount := map[uint]int{}
+	for _, chunk := range chunks {
+		lower := strings.ToLower(chunk.Text)
+		count := 0
+		for _, term := range terms {
+			count += strings.Count(lower, term)
+		}
+		if count > bestCount[chunk.FileID] {
+			best[chunk.FileID], bestCount[chunk.FileID] = chunk.ID, count
+		}
+	}
+	return best, nil
 }
 
 /*
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fmt"
+	"math"
+)
+
+// FileVector returns the mean of the stored chunk vectors of a file, or nil
+// if none of its chunks has a vector yet.
+func FileVector(fileID uint) ([]float32, error) {
+	table, err := VectorTable()
+	if err != nil {
+		return nil, err
+	}
+
+	var blobs [][]byte
+	err = Store.Table(table+" v").
+		Select("v.embedding").
+		Joins("JOIN chunks ON chunks.id = v.chunk_id").
+		Where("chunks.file_id = ?", fileID).
+		Scan(&blobs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(blobs))
+	for i, blob := range blobs {
+		vectors[i] = DecodeVector(blob)
+	}
+	return MeanVector(vectors), nil
+}
+
+// MeanVector averages vectors and scales the result back to unit length, so
+// it compares with chunk vectors like any other embedding.
+func MeanVector(vectors [][]float32) []float32 {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	mean := make([]float32, len(vectors[0]))
+	for _, vector := range vectors {
+		for i, value := range vector {
+			mean[i] += value
+		}
+	}
+	return normalizeVector(mean)
+}
+
+// normalizeVector returns vector scaled to unit length, the zero vector as is.
+func normalizeVector(vector []float32) []float32 {
+	var norm float64
+	for _, value := range vector {
+		norm += float64(value) * float64(value)
+	}
+	if norm == 0 {
+		return vector
+	}
+
+	scale := float32(1 / math.Sqrt(norm))
+	unit := make([]float32, len(vector))
+	for i, value := range vector {
+		unit[i] = value * scale
+	}
+	return unit
+}
+
+// SimilarFiles returns the files passing the filters of params whose chunks
+// are nearest to vector, best first, leaving out the file excludeID.
+func SimilarFiles(vector []float32, params *SearchParams, excludeID uint) ([]SearchResult, error) {
+	if len(vector) == 0 {
+		return nil, fmt.Errorf("no vector to compare with")
+	}
+
+	vectorBlob, err := EncodeVector(vector)
+	if err != nil {
+		return nil, err
+	}
+
+	// One extra in case the excluded file is among the nearest
+	hits, err := semanticSearch(vectorBlob, params, params.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileIDs, chunkIDs []uint
+	distances := map[uint]float64{}
+	for _, hit := range hits {
+		if hit.FileID == excludeID || SimilarityScore(hit.Distance) < params.MinScore || len(fileIDs) == params.Limit {
+			continue
+		}
+		fileIDs = append(fileIDs, hit.FileID)
+		chunkIDs = append(chunkIDs, hit.ChunkID)
+		distances[hit.FileID] = hit.Distance
+	}
+
+	var files []File
+	if err := Store.Where("id IN ?", fileIDs).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]File, len(files))
+	for _, file := range files {
+		byID[file.ID] = file
+	}
+
+	chunks, err := ChunksByID(chunkIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(fileIDs))
+	for i, id := range fileIDs {
+		distance := distances[id]
+		result := SearchResult{File: byID[id], Distance: &distance, Score: SimilarityScore(distance)}
+		if chunk, ok := chunks[chunkIDs[i]]; ok {
+			result.Chunk = &chunk
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// PageBreak separates pages in extracted PDF content.
+const PageBreak = '\f'
+
+const (
+	// snippetLines is how many consecutive lines a snippet spans.
+	snippetLines = 3
+
+	// snippetLength is the most bytes of text a snippet shows.
+	snippetLength = 300
+)
+
+// Snippet is the passage of a chunk that best matches a query.
+type Snippet struct {
+	// Text is the passage with its lines joined and whitespace collapsed
+	Text string
+	// Line is the line of the file the passage starts at
+	Line int
+	// Page is the PDF page the passage starts on, 0 for unpaginated files
+	Page int
+	// Matches are the byte ranges of query terms within Text
+	Matches [][2]int
+}
+
+// BestSnippet picks the lines of chunk containing the most distinct query
+// terms, falling back to the start of the chunk when no term occurs in it.
+func BestSnippet(chunk *Chunk, query string) Snippet {
+	terms := snippetTerms(query)
+
+	// Byte offsets of every line start, so lines map back to file positions
+	starts := []int{0}
+	for i := 0; i < len(chunk.Text)-1; i++ {
+		if chunk.Text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	line := func(n int) string {
+		end := len(chunk.Text)
+		if n+1 < len(starts) {
+			end = starts[n+1]
+		}
+		return chunk.Text[starts[n]:end]
+	}
+
+	best, bestDistinct, bestTotal := -1, 0, 0
+	for n := range starts {
+		if strings.TrimSpace(line(n)) == "" {
+			continue
+		}
+		if best < 0 {
+			best = n
+		}
+
+		// Passages start at a matching line, so the match isn't pushed out of view
+		first := strings.ToLower(line(n))
+		if !slices.ContainsFunc(terms, func(term string) bool { return strings.Contains(first, term) }) {
+			continue
+		}
+
+		var window strings.Builder
+		for i := n; i < min(n+snippetLines, len(starts)); i++ {
+			window.WriteString(strings.ToLower(line(i)))
+		}
+		distinct, total := 0, 0
+		for _, term := range terms {
+			if count := strings.Count(window.String(), term); count > 0 {
+				distinct++
+				total += count
+			}
+		}
+		if distinct > bestDistinct || (distinct == bestDistinct && total > bestTotal) {
+			best, bestDistinct, bestTotal = n, distinct, total
+		}
+	}
+	if best < 0 {
+		return Snippet{Line: chunk.StartLine, Page: chunk.Page}
+	}
+
+	var lines []string
+	for i := best; i < min(best+snippetLines, len(starts)); i++ {
+		if text := strings.Join(strings.Fields(line(i)), " "); text != "" {
+			lines = append(lines, text)
+		}
+	}
+
+	snippet := Snippet{
+		Text: strings.Join(lines, " "),
+		Line: chunk.StartLine + best,
+		Page: snippetPage(chunk, starts[best]),
+	}
+	snippet.Text = trimSnippet(snippet.Text, terms)
+	snippet.Matches = termMatches(snippet.Text, terms)
+	return snippet
+}
+
+// snippetTerms returns the distinct lowercase query terms worth matching.
+func snippetTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, term := range queryTerms(strings.ToLower(query)) {
+		if utf8.RuneCountInString(term) > 1 && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// snippetPage returns the page the text at offset within chunk is on.
+func snippetPage(chunk *Chunk, offset int) int {
+	if chunk.Page == 0 {
+		return 0
+	}
+
+	// Chunk.Page already counts a form feed the chunk starts with
+	page := chunk.Page
+	if strings.HasPrefix(chunk.Text, string(PageBreak)) {
+		page--
+	}
+
+	// A passage starting with form feeds is on the page after them
+	for offset < len(chunk.Text) && chunk.Text[offset] == PageBreak {
+		offset++
+	}
+	return page + strings.Count(chunk.Text[:offset], string(PageBreak))
+}
+
+// trimSnippet shortens text to about snippetLength bytes, keeping the first
+// query term in view.
+func trimSnippet(text string, terms []string) string {
+	if len(text) <= snippetLength {
+		return text
+	}
+
+	start := len(text)
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && i < start {
+			start = i
+		}
+	}
+	if start == len(text) {
+		start = 0
+	}
+	// Show a little context before the match
+	start = max(0, start-snippetLength/4)
+	end := min(len(text), start+snippetLength)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	trimmed := text[start:end]
+	if start > 0 {
+		trimmed = "…" + trimmed
+	}
+	if end < len(text) {
+		trimmed += "…"
+	}
+	return trimmed
+}
+
+// termMatches finds every case-insensitive occurrence of terms in text.
+func termMatches(text string, terms []string) [][2]int {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// Longest first, so overlapping terms highlight the longer match
+	patterns := make([]string, len(terms))
+	for i, term := range terms {
+		patterns[i] = regexp.QuoteMeta(term)
+	}
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return len(patterns[i]) > len(patterns[j])
+	})
+
+	var matches [][2]int
+	for _, match := range regexp.MustCompile("(?i)"+strings.Join(patterns, "|")).FindAllStringIndex(text, -1) {
+		matches = append(matches, [2]int{match[0], match[1]})
+	}
+	return matches
+}
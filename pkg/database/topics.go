@@ -0,0 +1,287 @@
+package database
+
+import (
+	"lamina/pkg/ai"
+	"math"
+	"math/rand"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	// topicIterations caps the k-means refinement rounds.
+	topicIterations = 50
+
+	// topicTerms is how many TF-IDF terms describe a topic.
+	topicTerms = 6
+
+	// topicExcerptLength is how many bytes of each file's content are kept for labelling.
+	topicExcerptLength = 500
+)
+
+// Topic is a cluster of files with similar content.
+type Topic struct {
+	// Terms are the words most characteristic of the topic by TF-IDF
+	Terms []string
+	// Files are ordered by how central they are to the topic
+	Files []TopicFile
+}
+
+// TopicFile is a member of a Topic.
+type TopicFile struct {
+	Path string
+	// Score is the similarity of the file to the topic centroid, 0-1
+	Score float64
+	// Excerpt is the start of the file's content
+	Excerpt string
+}
+
+// ClusterTopics groups the indexed files into k topics by spherical k-means
+// over their mean chunk vectors, largest topic first. A k of 0 picks one from
+// the corpus size. seed makes the clustering reproducible.
+func ClusterTopics(k int, seed int64) ([]Topic, error) {
+	fileVectors, err := meanFileVectors()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(fileVectors))
+	for id := range fileVectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	vectors := make([][]float32, len(ids))
+	for i, id := range ids {
+		vectors[i] = fileVectors[id]
+	}
+
+	if k <= 0 {
+		// Rule of thumb, within what a person can take in at once
+		k = max(2, min(20, int(math.Sqrt(float64(len(ids))/2))))
+	}
+	k = min(k, len(ids))
+
+	assignments, centroids := kMeans(vectors, k, rand.New(rand.NewSource(seed)))
+
+	topics := make([]Topic, k)
+	for i := range ids {
+		cluster := assignments[i]
+		topics[cluster].Files = append(topics[cluster].Files, TopicFile{
+			Score: SimilarityScore(math.Sqrt(max(0, 2-2*dot(vectors[i], centroids[cluster])))),
+		})
+	}
+
+	if err := describeTopics(topics, ids, assignments); err != nil {
+		return nil, err
+	}
+
+	var result []Topic
+	for _, topic := range topics {
+		if len(topic.Files) == 0 {
+			continue
+		}
+		sort.SliceStable(topic.Files, func(i, j int) bool {
+			return topic.Files[i].Score > topic.Files[j].Score
+		})
+		result = append(result, topic)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return len(result[i].Files) > len(result[j].Files)
+	})
+	return result, nil
+}
+
+// kMeans clusters unit vectors into k groups by cosine similarity, seeding
+// centroids with k-means++. It returns each vector's cluster and the centroids.
+func kMeans(vectors [][]float32, k int, rng *rand.Rand) ([]int, [][]float32) {
+	// k-means++: each next centroid is picked proportionally to its squared
+	// distance from the nearest centroid so far
+	centroids := [][]float32{vectors[rng.Intn(len(vectors))]}
+	nearest := make([]float64, len(vectors))
+	for i := range nearest {
+		nearest[i] = math.Inf(1)
+	}
+	for len(centroids) < k {
+		var total float64
+		for i, vector := range vectors {
+			distance := max(0, 2-2*dot(vector, centroids[len(centroids)-1]))
+			nearest[i] = min(nearest[i], distance)
+			total += nearest[i]
+		}
+		if total == 0 {
+			// Fewer distinct vectors than clusters
+			break
+		}
+
+		target := rng.Float64() * total
+		pick := len(vectors) - 1
+		for i, distance := range nearest {
+			if target -= distance; target <= 0 {
+				pick = i
+				break
+			}
+		}
+		centroids = append(centroids, vectors[pick])
+	}
+
+	assignments := make([]int, len(vectors))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+	for range topicIterations {
+		changed := false
+		for i, vector := range vectors {
+			best, bestSimilarity := 0, math.Inf(-1)
+			for c, centroid := range centroids {
+				if similarity := dot(vector, centroid); similarity > bestSimilarity {
+					best, bestSimilarity = c, similarity
+				}
+			}
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		// Move each centroid to the normalised mean of its members
+		members := make([][][]float32, len(centroids))
+		for i, vector := range vectors {
+			members[assignments[i]] = append(members[assignments[i]], vector)
+		}
+		for c := range centroids {
+			if len(members[c]) > 0 {
+				centroids[c] = MeanVector(members[c])
+			}
+		}
+	}
+	return assignments, centroids
+}
+
+// describeTopics fills in the paths, excerpts and TF-IDF terms of topics,
+// whose Files are in the order of ids.
+func describeTopics(topics []Topic, ids []uint, assignments []int) error {
+	// Where each file sits in its topic's Files
+	cluster := make(map[uint]int, len(ids))
+	position := make(map[uint]int, len(ids))
+	next := make([]int, len(topics))
+	for i, id := range ids {
+		cluster[id] = assignments[i]
+		position[id] = next[assignments[i]]
+		next[assignments[i]]++
+	}
+
+	// Stream contents rather than holding the whole corpus in memory
+	rows, err := Store.Model(&File{}).Select("id", "path", "content").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	documentFrequency := map[string]int{}
+	termFrequency := make([]map[string]int, len(topics))
+	for i := range termFrequency {
+		termFrequency[i] = map[string]int{}
+	}
+	documents := 0
+	for rows.Next() {
+		var file File
+		if err := Store.ScanRows(rows, &file); err != nil {
+			return err
+		}
+		documents++
+
+		terms := ai.Terms(file.Content)
+		seen := map[string]bool{}
+		for _, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				documentFrequency[term]++
+			}
+		}
+
+		c, clustered := cluster[file.ID]
+		if !clustered {
+			continue
+		}
+		for _, term := range terms {
+			termFrequency[c][term]++
+		}
+
+		excerpt := file.Content
+		if len(excerpt) > topicExcerptLength {
+			end := topicExcerptLength
+			for end > 0 && !utf8.RuneStart(excerpt[end]) {
+				end--
+			}
+			excerpt = excerpt[:end]
+		}
+		member := &topics[c].Files[position[file.ID]]
+		member.Path = file.Path
+		member.Excerpt = excerpt
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for c := range topics {
+		type weighted struct {
+			term   string
+			weight float64
+		}
+		var terms []weighted
+		for term, count := range termFrequency[c] {
+			idf := math.Log(float64(documents) / float64(documentFrequency[term]))
+			terms = append(terms, weighted{term, float64(count) * idf})
+		}
+		sort.Slice(terms, func(i, j int) bool {
+			if terms[i].weight != terms[j].weight {
+				return terms[i].weight > terms[j].weight
+			}
+			return terms[i].term < terms[j].term
+		})
+		for _, term := range terms[:min(topicTerms, len(terms))] {
+			if term.weight > 0 {
+				topics[c].Terms = append(topics[c].Terms, term.term)
+			}
+		}
+	}
+	return nil
+}
+
+// meanFileVectors returns the mean chunk vector of every file with vectors, by file ID.
+func meanFileVectors() (map[uint][]float32, error) {
+	table, err := VectorTable()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		FileID    uint
+		Embedding []byte
+	}
+	err = Store.Table(table + " v").
+		Select("chunks.file_id, v.embedding").
+		Joins("JOIN chunks ON chunks.id = v.chunk_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	chunkVectors := map[uint][][]float32{}
+	for _, row := range rows {
+		chunkVectors[row.FileID] = append(chunkVectors[row.FileID], DecodeVector(row.Embedding))
+	}
+
+	vectors := make(map[uint][]float32, len(chunkVectors))
+	for fileID, chunks := range chunkVectors {
+		vectors[fileID] = MeanVector(chunks)
+	}
+	return vectors, nil
+}
@@ -0,0 +1,162 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/database"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pendingFile is an extracted file waiting to be embedded.
+type pendingFile struct {
+	path        string
+	content     string
+	contentHash string
+	size        int64
+	modTime     time.Time
+	device      uint64
+	inode       uint64
+	chunks      []database.Chunk
+}
+
+// enqueue adds a file to the pending batch, flushing once it holds batchSize chunks.
+// A file queued again before the flush replaces its earlier content.
+func (i *Indexer) enqueue(ctx context.Context, pending *pendingFile) {
+	i.mu.Lock()
+	replaced := false
+	for idx, queued := range i.pending {
+		if queued.path == pending.path {
+			i.pending[idx] = pending
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		i.pending = append(i.pending, pending)
+	}
+	chunks := 0
+	for _, queued := range i.pending {
+		chunks += len(queued.chunks)
+	}
+	full := chunks >= i.batchSize
+	i.mu.Unlock()
+
+	if full {
+		i.flush(ctx)
+	}
+}
+
+// dropPending removes path, and every file below it, from the pending batch.
+func (i *Indexer) dropPending(path string) {
+	prefix := strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	kept := i.pending[:0]
+	for _, queued := range i.pending {
+		if queued.path != path && !strings.HasPrefix(queued.path, prefix) {
+			kept = append(kept, queued)
+		}
+	}
+	i.pending = kept
+}
+
+// flush embeds all pending files and saves them.
+func (i *Indexer) flush(ctx context.Context) {
+	// Serialize flushes so batches are written in the order they were queued
+	i.flushMu.Lock()
+	defer i.flushMu.Unlock()
+
+	i.mu.Lock()
+	if time.Now().Before(i.retryAt) {
+		// The provider is failing, the files stay queued until it may be back
+		i.mu.Unlock()
+		return
+	}
+	batch := i.pending
+	i.pending = nil
+	i.mu.Unlock()
+
+	if len(batch) > 0 {
+		i.embedBatch(ctx, batch)
+	}
+}
+
+// embedBatch embeds the chunks of files in requests of batchSize and maps the
+// vectors back to their files by position.
+func (i *Indexer) embedBatch(ctx context.Context, files []*pendingFile) {
+	embedded, err := i.embedFiles(ctx, files)
+	i.saveEmbedded(files, embedded, err)
+}
+
+// embedFiles embeds the chunks of files without writing to the database.
+func (i *Indexer) embedFiles(ctx context.Context, files []*pendingFile) (*cachedEmbeddings, error) {
+	// A reindex may have switched models under a running daemon
+	if err := database.CheckEmbeddingModel(i.provider.EmbeddingModel(), i.provider.Dimensions()); err != nil {
+		return nil, err
+	}
+
+	var hashes, contents []string
+	for _, file := range files {
+		for _, chunk := range file.chunks {
+			hashes = append(hashes, chunk.ContentHash)
+			contents = append(contents, chunk.Text)
+		}
+	}
+
+	embedded, err := embedCached(ctx, i.provider, hashes, contents, i.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	return embedded, nil
+}
+
+// requeue queues files that failed to embed again, unless newer content of
+// them was queued meanwhile, and holds off flushing for retryDelay.
+func (i *Indexer) requeue(files []*pendingFile) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	queued := map[string]bool{}
+	for _, pending := range i.pending {
+		queued[pending.path] = true
+	}
+	for _, file := range files {
+		if !queued[file.path] {
+			i.pending = append(i.pending, file)
+		}
+	}
+	i.retryAt = time.Now().Add(i.retryDelay)
+}
+
+// saveEmbedded caches the vectors embedFiles computed for files and saves the
+// files with them, or reports err for each file. Files that failed for a
+// transient reason are queued again rather than dropped.
+func (i *Indexer) saveEmbedded(files []*pendingFile, embedded *cachedEmbeddings, err error) {
+	if err != nil && ai.IsTransient(err) {
+		fmt.Printf("⏳ Embedding %d files failed, retrying in %s: %v\n", len(files), i.retryDelay, err)
+		i.requeue(files)
+		return
+	}
+	if err != nil {
+		for _, file := range files {
+			fmt.Printf("❌ Error indexing file %s: %v\n", file.path, err)
+		}
+		return
+	}
+	embedded.record()
+
+	offset := 0
+	for _, file := range files {
+		fileEmbeddings := embedded.vectors[offset : offset+len(file.chunks)]
+		offset += len(file.chunks)
+
+		if err := i.saveFile(file, fileEmbeddings); err != nil {
+			fmt.Printf("❌ Error saving file %s: %v\n", file.path, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"lamina/pkg/ai"
+	"lamina/pkg/database"
+	"lamina/pkg/watcher"
+)
+
+// failingProvider fails to embed with err while failing is set.
+type failingProvider struct {
+	testProvider
+	err     error
+	failing atomic.Bool
+}
+
+func (p *failingProvider) EmbedDocuments(ctx context.Context, contents []string) ([][]float32, error) {
+	if p.failing.Load() {
+		return nil, p.err
+	}
+	return p.testProvider.EmbedDocuments(ctx, contents)
+}
+
+func TestFailedBatchesAreQueuedAgain(t *testing.T) {
+	setConfig(t, "provider", "test")
+	setConfig(t, "embedding_dimensions", testDimensions)
+	defer openTestStorage(t)()
+
+	root := t.TempDir()
+	tree := map[string]string{}
+	for n := range 6 {
+		tree[fmt.Sprintf("%d.txt", n)] = fmt.Sprintf("Note number %d.", n)
+	}
+	writeTree(t, root, tree)
+
+	policy, err := watcher.NewPolicy([]string{root}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &failingProvider{err: fmt.Errorf("%w: test", ai.ErrCircuitOpen)}
+	provider.failing.Store(true)
+	i := &Indexer{
+		provider:       provider,
+		policy:         policy,
+		batchSize:      2,
+		chunkSize:      64,
+		extractWorkers: 2,
+		embedWorkers:   2,
+	}
+
+	ctx := context.Background()
+	if err := i.indexTree(ctx, root); err != nil {
+		t.Fatalf("indexTree: %v", err)
+	}
+	if len(i.pending) != len(tree) {
+		t.Fatalf("%d files queued after the provider failed, want %d", len(i.pending), len(tree))
+	}
+
+	provider.failing.Store(false)
+	i.flush(ctx)
+
+	var count int64
+	if err := database.Store.Model(&database.File{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != int64(len(tree)) {
+		t.Errorf("%d files indexed once the provider is back, want %d", count, len(tree))
+	}
+	if len(i.pending) != 0 {
+		t.Errorf("%d files still queued", len(i.pending))
+	}
+}
@@ -0,0 +1,122 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/database"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+)
+
+// embedWithCache returns one vector per content, reusing cached vectors for
+// content hashes the provider's model has already embedded. Only the misses
+// are sent to the provider, each distinct content once and at most batchSize
+// per request, and then cached.
+func embedWithCache(ctx context.Context, provider ai.Provider, hashes, contents []string, batchSize int) ([][]float32, error) {
+	result, err := embedCached(ctx, provider, hashes, contents, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	result.record()
+	return result.vectors, nil
+}
+
+// cachedEmbeddings is the outcome of embedding through the cache, before the
+// cache itself is updated.
+type cachedEmbeddings struct {
+	model   string
+	vectors [][]float32
+	hits    int
+	// hitHashes are the distinct content hashes served from the cache
+	hitHashes []string
+	// fresh are the vectors the provider computed, by content hash
+	freshHashes []string
+	fresh       [][]float32
+}
+
+// embedCached is embedWithCache without writing to the database, so
+// concurrent embedding leaves the writes to a single goroutine calling record.
+func embedCached(ctx context.Context, provider ai.Provider, hashes, contents []string, batchSize int) (*cachedEmbeddings, error) {
+	model := provider.EmbeddingModel()
+
+	cached, err := database.CachedEmbeddings(model, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(contents))
+	used := map[string]bool{}
+	missing := map[string][]int{}
+	var hitHashes, missHashes, missContents []string
+	for idx, hash := range hashes {
+		if vector, ok := cached[hash]; ok && len(vector) == provider.Dimensions() {
+			vectors[idx] = vector
+			if !used[hash] {
+				used[hash] = true
+				hitHashes = append(hitHashes, hash)
+			}
+			continue
+		}
+		if _, seen := missing[hash]; !seen {
+			missHashes = append(missHashes, hash)
+			missContents = append(missContents, contents[idx])
+		}
+		missing[hash] = append(missing[hash], idx)
+	}
+
+	result := &cachedEmbeddings{
+		model:       model,
+		vectors:     vectors,
+		hits:        len(contents) - len(missHashes),
+		hitHashes:   hitHashes,
+		freshHashes: missHashes,
+	}
+	if len(missContents) == 0 {
+		return result, nil
+	}
+
+	embeddings := make([][]float32, 0, len(missContents))
+	for start := 0; start < len(missContents); start += batchSize {
+		end := min(start+batchSize, len(missContents))
+
+		batch, err := provider.EmbedDocuments(ctx, missContents[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(batch))
+		}
+		embeddings = append(embeddings, batch...)
+	}
+
+	for idx, hash := range missHashes {
+		for _, target := range missing[hash] {
+			vectors[target] = embeddings[idx]
+		}
+	}
+	result.fresh = embeddings
+	return result, nil
+}
+
+// record stores the cache statistics, marks the cached vectors used and
+// stores the freshly computed ones.
+func (c *cachedEmbeddings) record() {
+	if err := database.RecordCacheLookups(c.hits, len(c.freshHashes)); err != nil {
+		fmt.Printf("⚠️ Could not record cache stats: %v\n", err)
+	}
+	if err := database.MarkCacheUsed(c.model, c.hitHashes); err != nil {
+		fmt.Printf("⚠️ Could not record cache stats: %v\n", err)
+	}
+
+	for idx, hash := range c.freshHashes {
+		blob, err := sqlite_vec.SerializeFloat32(c.fresh[idx])
+		if err != nil {
+			fmt.Printf("⚠️ Could not cache embedding: %v\n", err)
+			continue
+		}
+		if err := database.CacheEmbedding(hash, c.model, blob, len(c.fresh[idx])); err != nil {
+			fmt.Printf("⚠️ Could not cache embedding: %v\n", err)
+		}
+	}
+}
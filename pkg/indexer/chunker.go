@@ -0,0 +1,97 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"lamina/pkg/database"
+	"strings"
+	"unicode/utf8"
+)
+
+// pageBreak separates pages in extracted PDF content.
+const pageBreak = database.PageBreak
+
+// chunkContent splits content into chunks of about size bytes that overlap by
+// roughly overlap bytes. Chunks end on line boundaries unless a single line is
+// longer than size. Byte, line and page offsets are recorded for citations.
+func chunkContent(content string, size, overlap int) []database.Chunk {
+	if size <= 0 {
+		size = len(content)
+	}
+	overlap = max(0, min(overlap, size/2))
+
+	paginated := strings.ContainsRune(content, pageBreak)
+
+	var chunks []database.Chunk
+	start := 0
+	for start < len(content) {
+		end := chunkEnd(content, start, size)
+
+		text := content[start:end]
+		if strings.TrimSpace(text) != "" {
+			chunk := database.Chunk{
+				Ordinal:     len(chunks),
+				StartByte:   start,
+				EndByte:     end,
+				StartLine:   strings.Count(content[:start], "\n") + 1,
+				Text:        text,
+				ContentHash: fmt.Sprintf("%x", sha256.Sum256([]byte(text))),
+			}
+			chunk.EndLine = chunk.StartLine + strings.Count(strings.TrimSuffix(text, "\n"), "\n")
+			if paginated {
+				chunk.Page = strings.Count(content[:start], string(pageBreak)) + 1
+				// Don't attribute a chunk to the page it merely starts at the very end of
+				if strings.HasPrefix(text, string(pageBreak)) {
+					chunk.Page++
+				}
+			}
+			chunks = append(chunks, chunk)
+		}
+
+		if end >= len(content) {
+			break
+		}
+		start = chunkStart(content, start, end, overlap)
+	}
+	return chunks
+}
+
+// chunkEnd finds where a chunk beginning at start should end: after the last
+// newline within size bytes, or at size bytes on a rune boundary.
+func chunkEnd(content string, start, size int) int {
+	end := start + size
+	if end >= len(content) {
+		return len(content)
+	}
+
+	if newline := strings.LastIndexByte(content[start:end], '\n'); newline > 0 {
+		return start + newline + 1
+	}
+
+	for end > start && !utf8.RuneStart(content[end]) {
+		end--
+	}
+	if end == start {
+		// Degenerate input, never make a chunk without progress
+		end = start + size
+	}
+	return end
+}
+
+// chunkStart finds the start of the chunk after [start, end), stepping back
+// by about overlap bytes to a line start while always making progress.
+func chunkStart(content string, start, end, overlap int) int {
+	next := end - overlap
+	if next <= start {
+		return end
+	}
+
+	if newline := strings.IndexByte(content[next:end], '\n'); newline >= 0 && next+newline+1 < end {
+		return next + newline + 1
+	}
+
+	for next < end && !utf8.RuneStart(content[next]) {
+		next++
+	}
+	return next
+}
@@ -6,7 +6,6 @@ import (
 	"crypto/sha256"
 	"encoding/xml"
 	"fmt"
-	"lamina/pkg/ai"
 	"lamina/pkg/database"
 	"os"
 	"path"
@@ -14,108 +13,131 @@ import (
 
 	"github.com/gomutex/godocx"
 	"github.com/ledongthuc/pdf"
-
-	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
-	"gorm.io/gorm/clause"
 )
 
+// indexFile extracts a file and queues it for embedding if its content changed.
 func (i *Indexer) indexFile(ctx context.Context, filePath string) error {
+	pending, err := i.prepareFile(filePath)
+	if err != nil || pending == nil {
+		return err
+	}
+
+	i.enqueue(ctx, pending)
+	return nil
+}
+
+// prepareFile extracts and hashes a file, returning nil if it needs no indexing.
+func (i *Indexer) prepareFile(filePath string) (*pendingFile, error) {
+	pending, err := i.extractFile(filePath)
+	if err != nil || pending == nil {
+		return nil, err
+	}
+
+	needed, err := i.reconcile(pending)
+	if err != nil || !needed {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// extractFile reads, hashes and chunks a file without touching the database,
+// returning nil for unsupported files.
+func (i *Indexer) extractFile(filePath string) (*pendingFile, error) {
 	// Get file content first
 	content, err := i.getFileContent(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Skip if no content extracted (unsupported file type)
 	if len(content) == 0 {
 		fmt.Printf("⏭️  Skipping unsupported file type: %s\n", filePath)
-		return nil
+		return nil, nil
 	}
 
-	// Check if we should reindex based on content
-	shouldReindex, err := i.shouldReindexWithContent(filePath, content)
+	info, err := os.Stat(filePath)
 	if err != nil {
-		return err
-	}
-	if !shouldReindex {
-		fmt.Printf("⏭️  Skipping unchanged file: %s\n", filePath)
-		return nil
+		return nil, err
 	}
+	device, inode, _ := fileIdentity(info)
+
+	return &pendingFile{
+		path:        filePath,
+		content:     string(content),
+		contentHash: fmt.Sprintf("%x", sha256.Sum256(content)),
+		size:        info.Size(),
+		modTime:     info.ModTime(),
+		device:      device,
+		inode:       inode,
+		chunks:      chunkContent(string(content), i.chunkSize, i.chunkOverlap),
+	}, nil
+}
 
-	info, _ := os.Stat(filePath)
-	contentHash := fmt.Sprintf("%x", sha256.Sum256(content))
+// reconcile ties an extracted file to the index, reporting whether it needs
+// embedding.
+func (i *Indexer) reconcile(pending *pendingFile) (bool, error) {
+	// A file moved here keeps its vectors
+	if err := i.matchIndexedFile(pending.path, pending.device, pending.inode, pending.contentHash); err != nil {
+		return false, err
+	}
 
-	// Generate embeddings
-	embeddingFloats, err := ai.GenerateEmbedding(ctx, string(content))
+	// Check if we should reindex based on content
+	shouldReindex, err := i.shouldReindexWithContent(pending)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if !shouldReindex {
+		fmt.Printf("⏭️  Skipping unchanged file: %s\n", pending.path)
+		return false, nil
 	}
+	return true, nil
+}
 
-	vectorBlob, err := sqlite_vec.SerializeFloat32(embeddingFloats)
-	if err != nil {
-		return err
+// saveFile stores a file's metadata, its chunks and their embeddings.
+func (i *Indexer) saveFile(pending *pendingFile, embeddings [][]float32) error {
+	vectors := make([][]byte, len(embeddings))
+	for idx, embedding := range embeddings {
+		vectorBlob, err := database.EncodeVector(embedding)
+		if err != nil {
+			return err
+		}
+		vectors[idx] = vectorBlob
 	}
 
 	// Save to DB
 	file := database.File{
-		Path:        filePath,
-		ContentHash: contentHash,
-		Size:        info.Size(),
-		ModTime:     info.ModTime(),
-		Content:     string(content),
+		Path:        pending.path,
+		ContentHash: pending.contentHash,
+		Size:        pending.size,
+		ModTime:     pending.modTime,
+		Content:     pending.content,
+		Device:      pending.device,
+		Inode:       pending.inode,
+
+		EmbeddingModel:      i.provider.EmbeddingModel(),
+		EmbeddingDimensions: i.provider.Dimensions(),
 	}
 
-	// Use ON CONFLICT DO UPDATE for proper upsert
-	if err := database.Store.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "path"}},
-		DoUpdates: clause.AssignmentColumns([]string{"content_hash", "size", "mod_time", "content", "updated_at"}),
-	}).Create(&file).Error; err != nil {
+	if err := database.SaveFileWithChunks(&file, pending.chunks, vectors); err != nil {
 		return err
-
-	}
-
-	// Save embedding
-	// Try to update first
-	result := database.Store.Exec(`
-	    UPDATE vec_embeddings SET embedding = ? WHERE file_id = ?
-	`, vectorBlob, file.ID)
-
-	// If no rows were affected, insert new record
-	if result.RowsAffected == 0 {
-		err = database.Store.Exec(`
-			INSERT INTO vec_embeddings(file_id, embedding) 
-			VALUES (?, ?)
-		    `, file.ID, vectorBlob).Error
-		if err != nil {
-			return err
-		}
 	}
 
-	fmt.Printf("✅ Successfully indexed: %s\n", file.ContentHash)
+	fmt.Printf("✅ Successfully indexed: %s (%d chunks)\n", file.Path, len(pending.chunks))
 	return nil
 }
 
-func (i *Indexer) shouldReindexWithContent(filePath string, content []byte) (bool, error) {
-	// Get file info
-	info, err := os.Stat(filePath)
-	if err != nil {
-		return false, err
-	}
-
-	// Hash the extracted content
-	currentHash := fmt.Sprintf("%x", sha256.Sum256(content))
-
+func (i *Indexer) shouldReindexWithContent(pending *pendingFile) (bool, error) {
 	// Check if file exists in DB
 	var existingFile database.File
-	err = database.Store.Where("path = ?", filePath).First(&existingFile).Error
+	err := database.Store.Where("path = ?", pending.path).First(&existingFile).Error
 	if err != nil {
 		// File not in DB, needs indexing
 		return true, nil
 	}
 
 	// Compare hash and mod time
-	return existingFile.ContentHash != currentHash ||
-		existingFile.ModTime.Before(info.ModTime()), nil
+	return existingFile.ContentHash != pending.contentHash ||
+		existingFile.ModTime.Before(pending.modTime), nil
 }
 
 func (i *Indexer) shouldReindex(filePath string) (bool, error) {
@@ -193,19 +215,41 @@ func (i *Indexer) getFileContent(filepath string) ([]byte, error) {
 	}
 }
 
+// extractPDFContent extracts plain text page by page, separating pages with
+// a form feed so chunks can be attributed to their page.
 func (i *Indexer) extractPDFContent(filepath string) ([]byte, error) {
 	f, r, err := pdf.Open(filepath)
 	if err != nil {
 		fmt.Printf("📄 PDF extraction failed for path: %s\n", filepath)
+		return nil, err
 	}
 	defer f.Close()
 
 	var buf bytes.Buffer
-	b, err := r.GetPlainText()
-	if err != nil {
-		fmt.Printf("📄 PDF extraction to Plain text failed for path: %s\n", filepath)
+	fonts := make(map[string]*pdf.Font)
+	for num := 1; num <= r.NumPage(); num++ {
+		if num > 1 {
+			buf.WriteRune(pageBreak)
+		}
+
+		page := r.Page(num)
+		if page.V.IsNull() {
+			continue
+		}
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+
+		text, err := page.GetPlainText(fonts)
+		if err != nil {
+			fmt.Printf("📄 PDF extraction to Plain text failed for page %d of path: %s\n", num, filepath)
+			continue
+		}
+		buf.WriteString(text)
 	}
-	buf.ReadFrom(b)
 	return buf.Bytes(), nil
 }
 
@@ -2,34 +2,88 @@ package indexer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
-	"regexp"
+	"sync"
+	"time"
 
-	"github.com/tmc/langchaingo/embeddings"
+	"lamina/pkg/ai"
 	"lamina/pkg/config"
+	"lamina/pkg/database"
 	"lamina/pkg/watcher"
 )
 
 // Indexer manages file indexing.
 type Indexer struct {
-	watcher   *watcher.FileWatcher
-	embedder  *embeddings.Embedder
-	filetypes []*regexp.Regexp
+	watcher  *watcher.FileWatcher
+	provider ai.Provider
+	// policy decides which files are indexed, shared with the watcher
+	policy *watcher.Policy
+
+	// pending files are embedded in batches of batchSize, at least every flushInterval
+	mu            sync.Mutex
+	flushMu       sync.Mutex
+	pending       []*pendingFile
+	batchSize     int
+	flushInterval time.Duration
+	// files that failed to embed for a transient reason are flushed again from retryAt on
+	retryAt    time.Time
+	retryDelay time.Duration
+
+	chunkSize    int
+	chunkOverlap int
+
+	// directories are indexed by this many concurrent extractions and embedding batches
+	extractWorkers int
+	embedWorkers   int
+
+	// forcePrune removes files the policy doesn't allow on start even if its settings didn't change
+	forcePrune bool
 }
 
-// NewIndexer creates a new Indexer with a FileWatcher.
-func NewIndexer() (*Indexer, error) {
-	w, err := watcher.NewFileWatcher()
+// policySetting stores the fingerprint of the policy the index was last pruned by.
+const policySetting = "indexed_policy"
+
+// NewIndexer creates a new Indexer with a FileWatcher that embeds through provider.
+func NewIndexer(provider ai.Provider) (*Indexer, error) {
+	policy, err := watcher.PolicyFromConfig()
+	if err != nil {
+		return nil, err
+	}
+	w, err := watcher.NewFileWatcher(policy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create watcher: %w", err)
 	}
-	return &Indexer{watcher: w}, nil
+	return &Indexer{
+		watcher:       w,
+		provider:      provider,
+		policy:        policy,
+		batchSize:     max(config.GetEmbedBatchSize(), 1),
+		flushInterval: config.GetEmbedFlushInterval(),
+		retryDelay:    config.GetCircuitBreakerCooldown(),
+		chunkSize:     config.GetChunkSize(),
+		chunkOverlap:  config.GetChunkOverlap(),
+
+		extractWorkers: config.GetExtractWorkers(),
+		embedWorkers:   config.GetEmbedWorkers(),
+	}, nil
+}
+
+// ForcePrune makes Start remove the indexed files the policy doesn't allow,
+// even if its settings didn't change since the last start.
+func (i *Indexer) ForcePrune() {
+	i.forcePrune = true
 }
 
 // Start indexes watch_paths and listens for file events.
 func (i *Indexer) Start(ctx context.Context) error {
+	// Never mix vectors from different models in one index
+	if err := database.CheckEmbeddingModel(i.provider.EmbeddingModel(), i.provider.Dimensions()); err != nil {
+		return err
+	}
+
 	// Start the watcher
 	if err := i.watcher.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start watcher: %w", err)
@@ -58,43 +112,144 @@ func (i *Indexer) indexWatchPaths(ctx context.Context) error {
 			return fmt.Errorf("failed to index path %s: %w", path, err)
 		}
 	}
-	return nil
+
+	// Embed whatever is left of the last batch
+	i.flush(ctx)
+
+	return i.pruneOnStart()
 }
 
-// indexPath indexes a single directory.
-func (i *Indexer) indexPath(ctx context.Context, path string) error {
-	ignorePatterns := config.GetIgnorePatterns()
+// pruneOnStart removes the indexed files the policy doesn't allow if its
+// settings changed since the last start, or if forced. An index never pruned
+// before may hold files older versions allowed, those are only reported.
+func (i *Indexer) pruneOnStart() error {
+	recorded, err := database.GetSetting(policySetting)
+	if err != nil {
+		return err
+	}
+	fingerprint := i.policy.Fingerprint()
 
-	return filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+	switch {
+	case i.forcePrune || (recorded != "" && recorded != fingerprint):
+		if err := i.pruneExcluded(""); err != nil {
+			return err
+		}
+	case recorded == "":
+		excluded, err := i.excludedPaths("")
 		if err != nil {
 			return err
 		}
+		if len(excluded) > 0 {
+			fmt.Printf("⚠️ %d indexed files aren't allowed by the filetypes and ignore settings, run `lamina daemon --prune` to remove them\n", len(excluded))
+		}
+	}
+	return database.SetSetting(policySetting, fingerprint)
+}
 
-		if info.IsDir() {
-			for _, pattern := range ignorePatterns {
-				if matched, _ := filepath.Match(pattern, info.Name()); matched {
-					return filepath.SkipDir
-				}
-			}
-			return nil
+// pruneExcluded removes indexed files below dir, anywhere if empty, that
+// the policy no longer allows, e.g. after filetypes, the ignore settings or
+// an ignore file changed.
+func (i *Indexer) pruneExcluded(dir string) error {
+	paths, err := i.excludedPaths(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if _, err := database.DeleteFiles(path); err != nil {
+			return err
 		}
-		fullpath := fmt.Sprintf("%s/%s", path, info.Name())
+		fmt.Printf("🗑️  Removed from index, now ignored: %s\n", path)
+	}
+	return nil
+}
+
+// excludedPaths returns the indexed files below dir, anywhere if empty, that
+// the policy doesn't allow.
+func (i *Indexer) excludedPaths(dir string) ([]string, error) {
+	paths, err := database.IndexedPaths(dir)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := i.indexFile(ctx, fullpath); err != nil {
-			fmt.Printf("❌ Error indexing file %s: %v\n", fullpath, err)
+	var excluded []string
+	for _, path := range paths {
+		if !i.policy.Included(path) {
+			excluded = append(excluded, path)
 		}
-		return nil
-	})
+	}
+	return excluded, nil
+}
+
+// indexPath indexes a directory and everything below it the policy allows.
+func (i *Indexer) indexPath(ctx context.Context, path string) error {
+	// Files the watcher queued are saved first, as they were found first
+	i.flush(ctx)
+
+	return i.indexTree(ctx, path)
+}
+
+// handleEvent brings the index up to date with a change on disk.
+func (i *Indexer) handleEvent(ctx context.Context, event watcher.Event) {
+	if event.Op == watcher.OpRules {
+		fmt.Printf("🔄 Ignore rules changed in %s\n", event.Path)
+		if err := i.pruneExcluded(event.Path); err != nil {
+			fmt.Printf("❌ Error removing newly ignored files below %s: %v\n", event.Path, err)
+		}
+		// Picks up files no longer ignored
+		if err := i.indexPath(ctx, event.Path); err != nil {
+			fmt.Printf("❌ Error indexing directory %s: %v\n", event.Path, err)
+		}
+		return
+	}
+
+	if event.Op == watcher.OpRename {
+		i.movePath(event.OldPath, event.Path)
+	}
+
+	// A removed path may already be back, e.g. after an editor's atomic save,
+	// so what's on disk decides rather than the event alone
+	info, err := os.Stat(event.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Anything queued from before the removal must not come back
+		i.dropPending(event.Path)
+
+		removed, err := database.DeleteFiles(event.Path)
+		if err != nil {
+			fmt.Printf("❌ Error removing %s from the index: %v\n", event.Path, err)
+		} else if removed > 0 {
+			fmt.Printf("🗑️  Removed from index: %s (%d files)\n", event.Path, removed)
+		}
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Error indexing file after modification %s: %v\n", event.Path, err)
+		return
+	}
+
+	if info.IsDir() {
+		// A directory created or moved in, index what it holds
+		if err := i.indexPath(ctx, event.Path); err != nil {
+			fmt.Printf("❌ Error indexing directory %s: %v\n", event.Path, err)
+		}
+		return
+	}
+	if err := i.indexFile(ctx, event.Path); err != nil {
+		fmt.Printf("❌ Error indexing file after modification %s: %v\n", event.Path, err)
+	}
 }
 
 // processEvents handles file change events from the watcher.
 func (i *Indexer) processEvents(ctx context.Context) {
+	ticker := time.NewTicker(i.flushInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case filePath := <-i.watcher.Events():
-			if err := i.indexFile(ctx, filePath); err != nil {
-				fmt.Printf("❌ Error indexing file after modification %s: %v\n", filePath, err)
-			}
+		case event := <-i.watcher.Events():
+			i.handleEvent(ctx, event)
+		case <-ticker.C:
+			i.flush(ctx)
 		case <-ctx.Done():
 			return
 		}
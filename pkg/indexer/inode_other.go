@@ -0,0 +1,11 @@
+//go:build !unix
+
+package indexer
+
+import "os"
+
+// fileIdentity reports that files have no stable identity on this platform,
+// so moves are only recognised by content.
+func fileIdentity(info os.FileInfo) (device, inode uint64, ok bool) {
+	return 0, 0, false
+}
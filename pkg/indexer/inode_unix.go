@@ -0,0 +1,18 @@
+//go:build unix
+
+package indexer
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode of info, which a file keeps when
+// it's renamed or moved within its file system.
+func fileIdentity(info os.FileInfo) (device, inode uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Dev), uint64(stat.Ino), true
+}
@@ -0,0 +1,57 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"lamina/pkg/database"
+)
+
+// movePath moves the index entries of a renamed file or directory along with
+// it. The new path is indexed afterwards, which only embeds changed content.
+func (i *Indexer) movePath(oldPath, newPath string) {
+	// Content queued under the old path is queued again under the new one
+	i.dropPending(oldPath)
+
+	moved, err := database.MoveFiles(oldPath, newPath)
+	if err != nil {
+		fmt.Printf("❌ Error moving %s to %s in the index: %v\n", oldPath, newPath, err)
+		return
+	}
+	if moved > 0 {
+		fmt.Printf("🔀 Moved in index: %s → %s (%d files)\n", oldPath, newPath, moved)
+	}
+}
+
+// matchIndexedFile ties filePath to its row in the index. A file new to
+// filePath that is an indexed file whose path is gone, by device and inode or
+// else by content, was moved while nobody was watching, so its row is moved
+// here instead of embedding it again. The identity of the file is kept current.
+func (i *Indexer) matchIndexedFile(filePath string, device, inode uint64, contentHash string) error {
+	var count int64
+	if err := database.Store.Model(&database.File{}).Where("path = ?", filePath).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		candidates, err := database.MoveCandidates(device, inode, contentHash)
+		if err != nil {
+			return err
+		}
+		for _, candidate := range candidates {
+			// Hard links and copies still exist at their path
+			if _, err := os.Lstat(candidate.Path); !errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			i.movePath(candidate.Path, filePath)
+			break
+		}
+	}
+
+	if inode == 0 {
+		return nil
+	}
+	return database.SetFileIdentity(filePath, device, inode)
+}
@@ -0,0 +1,232 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// extractAhead is how many files per extract worker may be read ahead of the
+// oldest one still being extracted, bounding the memory held by the pipeline.
+const extractAhead = 4
+
+// extractedFile is the outcome of extracting the seq-th file of a walk.
+type extractedFile struct {
+	seq     int
+	path    string
+	pending *pendingFile
+	err     error
+}
+
+// fileBatch is the seq-th batch of files of a walk to embed and save.
+type fileBatch struct {
+	seq      int
+	files    []*pendingFile
+	embedded *cachedEmbeddings
+	err      error
+}
+
+// reconcileRequest asks the writer whether an extracted file needs embedding.
+type reconcileRequest struct {
+	pending *pendingFile
+	reply   chan reconcileReply
+}
+
+type reconcileReply struct {
+	needed bool
+	err    error
+}
+
+// indexTree indexes the files below path the policy allows in a pipeline:
+//
+//   - the walk numbers files in walk order,
+//   - extractWorkers read, extract, hash and chunk them concurrently,
+//   - a sequencer puts them back in walk order and batches them,
+//   - embedWorkers embed the batches concurrently,
+//   - and the calling goroutine is the only one writing to the database,
+//     in walk order.
+//
+// Files are thus checked against the index, batched and saved exactly as
+// one after another.
+func (i *Indexer) indexTree(ctx context.Context, path string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	paths := make(chan extractedFile)
+	extracted := make(chan extractedFile)
+	reconciles := make(chan reconcileRequest)
+	batches := make(chan fileBatch)
+	embedded := make(chan fileBatch)
+
+	// Tokens of files read ahead, returned once the sequencer takes them
+	ahead := make(chan struct{}, i.extractWorkers*extractAhead)
+
+	var walkErr error
+	go func() {
+		defer close(paths)
+		seq := 0
+		walkErr = filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !i.policy.Allows(filePath, info) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			select {
+			case ahead <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case paths <- extractedFile{seq: seq, path: filePath}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			seq++
+			return nil
+		})
+	}()
+
+	var extractors sync.WaitGroup
+	for range i.extractWorkers {
+		extractors.Add(1)
+		go func() {
+			defer extractors.Done()
+			for file := range paths {
+				file.pending, file.err = i.extractFile(file.path)
+				select {
+				case extracted <- file:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		extractors.Wait()
+		close(extracted)
+	}()
+
+	go i.sequence(extracted, ahead, reconciles, batches)
+
+	var embedders sync.WaitGroup
+	for range i.embedWorkers {
+		embedders.Add(1)
+		go func() {
+			defer embedders.Done()
+			for batch := range batches {
+				batch.embedded, batch.err = i.embedFiles(ctx, batch.files)
+				embedded <- batch
+			}
+		}()
+	}
+	go func() {
+		embedders.Wait()
+		close(embedded)
+	}()
+
+	i.write(reconciles, embedded)
+	return walkErr
+}
+
+// sequence restores the walk order of extracted files, asks the writer which
+// need embedding and groups those into batches of at least batchSize chunks,
+// as enqueue does.
+func (i *Indexer) sequence(extracted <-chan extractedFile, ahead <-chan struct{}, reconciles chan<- reconcileRequest, batches chan<- fileBatch) {
+	defer close(batches)
+	defer close(reconciles)
+
+	var batch []*pendingFile
+	chunks, seq := 0, 0
+	emit := func() {
+		if len(batch) > 0 {
+			batches <- fileBatch{seq: seq, files: batch}
+			batch, chunks = nil, 0
+			seq++
+		}
+	}
+
+	waiting := map[int]extractedFile{}
+	next := 0
+	reply := make(chan reconcileReply, 1)
+	for file := range extracted {
+		waiting[file.seq] = file
+		for {
+			file, ok := waiting[next]
+			if !ok {
+				break
+			}
+			delete(waiting, next)
+			next++
+			<-ahead
+
+			if file.err != nil {
+				fmt.Printf("❌ Error indexing file %s: %v\n", file.path, file.err)
+				continue
+			}
+			if file.pending == nil {
+				continue
+			}
+
+			reconciles <- reconcileRequest{pending: file.pending, reply: reply}
+			result := <-reply
+			if result.err != nil {
+				fmt.Printf("❌ Error indexing file %s: %v\n", file.path, result.err)
+				continue
+			}
+			if !result.needed {
+				continue
+			}
+
+			batch = append(batch, file.pending)
+			if chunks += len(file.pending.chunks); chunks >= i.batchSize {
+				emit()
+			}
+		}
+	}
+	emit()
+}
+
+// write is the single goroutine writing to the database while indexing a
+// tree. It answers reconcile requests and saves embedded batches in order.
+func (i *Indexer) write(reconciles <-chan reconcileRequest, embedded <-chan fileBatch) {
+	waiting := map[int]fileBatch{}
+	next := 0
+	for reconciles != nil || embedded != nil {
+		select {
+		case request, ok := <-reconciles:
+			if !ok {
+				reconciles = nil
+				continue
+			}
+			needed, err := i.reconcile(request.pending)
+			request.reply <- reconcileReply{needed: needed, err: err}
+
+		case batch, ok := <-embedded:
+			if !ok {
+				embedded = nil
+				continue
+			}
+			waiting[batch.seq] = batch
+			for {
+				batch, ok := waiting[next]
+				if !ok {
+					break
+				}
+				delete(waiting, next)
+				next++
+				i.saveEmbedded(batch.files, batch.embedded, batch.err)
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"lamina/pkg/ai"
+	"lamina/pkg/config"
+	"lamina/pkg/database"
+	"lamina/pkg/watcher"
+)
+
+// testDimensions is the length of the vectors of testProvider.
+const testDimensions = 4
+
+// testProvider embeds text into vectors derived from its hash, taking longer
+// for some texts so concurrent batches finish out of order.
+type testProvider struct{}
+
+func (testProvider) Name() string           { return "test" }
+func (testProvider) EmbeddingModel() string { return "test:hash" }
+func (testProvider) Dimensions() int        { return testDimensions }
+
+func (testProvider) EmbedDocuments(ctx context.Context, contents []string) ([][]float32, error) {
+	vectors := make([][]float32, len(contents))
+	for idx, content := range contents {
+		sum := sha256.Sum256([]byte(content))
+		vectors[idx] = make([]float32, testDimensions)
+		for d := range vectors[idx] {
+			vectors[idx][d] = float32(sum[d]) + 1
+		}
+		time.Sleep(time.Duration(sum[0]%4) * time.Millisecond)
+	}
+	return vectors, nil
+}
+
+func (p testProvider) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := p.EmbedDocuments(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (testProvider) GenerateStructured(ctx context.Context, prompt string, schema *ai.Schema) (string, error) {
+	return "", ai.ErrUnsupported
+}
+
+func (testProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return "", ai.ErrUnsupported
+}
+
+func (testProvider) GenerateStream(ctx context.Context, prompt string, onText func(text string) error) error {
+	return ai.ErrUnsupported
+}
+
+// setConfig overrides a configuration key for the duration of the test.
+func setConfig(t *testing.T, key string, value any) {
+	t.Helper()
+	previous := viper.Get(key)
+	config.Set(key, value)
+	t.Cleanup(func() { config.Set(key, previous) })
+}
+
+// writeTree creates the files of tree below root, all modified at the same time.
+func writeTree(t *testing.T, root string, tree map[string]string) {
+	t.Helper()
+	modTime := time.Date(2026, time.March, 18, 12, 0, 0, 0, time.UTC)
+	for name, content := range tree {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// openTestStorage opens a new database and returns a function closing it.
+func openTestStorage(t *testing.T) func() {
+	t.Helper()
+	setConfig(t, "database_path", filepath.Join(t.TempDir(), "lamina.db"))
+	if err := database.NewStorage(); err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	return func() {
+		if db, err := database.Store.DB(); err == nil {
+			db.Close()
+		}
+	}
+}
+
+// indexSnapshot is what an index run left in the database.
+type indexSnapshot struct {
+	files   []database.File
+	chunks  []database.Chunk
+	vectors map[uint][]byte
+}
+
+// snapshotIndex reads every file, chunk and vector row of the database.
+func snapshotIndex(t *testing.T) indexSnapshot {
+	t.Helper()
+	var snapshot indexSnapshot
+	if err := database.Store.Order("id").Find(&snapshot.files).Error; err != nil {
+		t.Fatal(err)
+	}
+	for idx := range snapshot.files {
+		snapshot.files[idx].CreatedAt = time.Time{}
+		snapshot.files[idx].UpdatedAt = time.Time{}
+	}
+	if err := database.Store.Order("id").Find(&snapshot.chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := database.VectorTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := database.Store.Raw(fmt.Sprintf("SELECT chunk_id, embedding FROM %s", table)).Rows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	snapshot.vectors = map[uint][]byte{}
+	for rows.Next() {
+		var chunkID uint
+		var vector []byte
+		if err := rows.Scan(&chunkID, &vector); err != nil {
+			t.Fatal(err)
+		}
+		snapshot.vectors[chunkID] = vector
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+// indexWithWorkers indexes root into a new database with the given number of
+// workers, moves a file while nobody watches and indexes root again.
+func indexWithWorkers(t *testing.T, root string, extractWorkers, embedWorkers int) indexSnapshot {
+	t.Helper()
+	defer openTestStorage(t)()
+
+	policy, err := watcher.NewPolicy([]string{root}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := &Indexer{
+		provider:       testProvider{},
+		policy:         policy,
+		batchSize:      3,
+		chunkSize:      64,
+		chunkOverlap:   8,
+		extractWorkers: extractWorkers,
+		embedWorkers:   embedWorkers,
+	}
+
+	ctx := context.Background()
+	if err := i.indexTree(ctx, root); err != nil {
+		t.Fatalf("indexTree: %v", err)
+	}
+
+	// Renaming keeps the inode and times, so every run sees the same tree
+	from, to := filepath.Join(root, "notes/report.txt"), filepath.Join(root, "archive/report.txt")
+	if err := os.Rename(from, to); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Rename(to, from)
+
+	if err := i.indexTree(ctx, root); err != nil {
+		t.Fatalf("indexTree after the move: %v", err)
+	}
+	return snapshotIndex(t)
+}
+
+func TestIndexTreeWorkersMatchSequential(t *testing.T) {
+	setConfig(t, "provider", "test")
+	setConfig(t, "embedding_dimensions", testDimensions)
+
+	shared := strings.Repeat("The same paragraph lives in several files. ", 4)
+	tree := map[string]string{
+		"notes/report.txt":   strings.Repeat("Quarterly numbers went up again. ", 6),
+		"archive/.keep":      "kept",
+		"copies/a.txt":       shared,
+		"copies/b.txt":       shared,
+		"copies/nested/c.md": shared,
+		"empty.txt":          "",
+	}
+	for n := range 30 {
+		tree[fmt.Sprintf("docs/%02d/doc.txt", n)] = strings.Repeat(fmt.Sprintf("Document %d sentence. ", n), n%7+1)
+	}
+
+	root := t.TempDir()
+	writeTree(t, root, tree)
+
+	sequential := indexWithWorkers(t, root, 1, 1)
+	parallel := indexWithWorkers(t, root, 4, 3)
+
+	if len(sequential.files) == 0 || len(sequential.chunks) <= len(sequential.files) {
+		t.Fatalf("indexed %d files with %d chunks, want several chunks per file", len(sequential.files), len(sequential.chunks))
+	}
+	// notes/ is walked last, so the moved row keeps the highest ID and a
+	// file indexed again would have a new one above it
+	var moved bool
+	for _, file := range sequential.files {
+		if strings.HasSuffix(file.Path, "archive/report.txt") {
+			moved = true
+			if file.ID != uint(len(sequential.files)) {
+				t.Errorf("the moved file has ID %d, was it indexed again?", file.ID)
+			}
+		}
+		if strings.HasSuffix(file.Path, "notes/report.txt") {
+			t.Errorf("%s is still indexed after moving", file.Path)
+		}
+	}
+	if !moved {
+		t.Errorf("the moved file isn't indexed at archive/report.txt")
+	}
+
+	if !reflect.DeepEqual(parallel.files, sequential.files) {
+		t.Errorf("files differ:\nparallel   %+v\nsequential %+v", parallel.files, sequential.files)
+	}
+	if !reflect.DeepEqual(parallel.chunks, sequential.chunks) {
+		t.Errorf("chunks differ:\nparallel   %+v\nsequential %+v", parallel.chunks, sequential.chunks)
+	}
+	if len(sequential.vectors) != len(sequential.chunks) {
+		t.Errorf("%d vectors for %d chunks", len(sequential.vectors), len(sequential.chunks))
+	}
+	if !reflect.DeepEqual(parallel.vectors, sequential.vectors) {
+		t.Errorf("vectors differ between %d parallel and %d sequential ones", len(parallel.vectors), len(sequential.vectors))
+	}
+}
@@ -0,0 +1,156 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/config"
+	"lamina/pkg/database"
+)
+
+// reindexCatchUpPasses bounds how often chunks added during the build are
+// embedded before giving up on swapping the table in.
+const reindexCatchUpPasses = 5
+
+// Reindex re-embeds every stored chunk with provider into a shadow vector
+// table while searches keep using the active table. Once complete, the shadow
+// table is swapped in atomically and the old one dropped.
+func Reindex(ctx context.Context, provider ai.Provider, progress func(done, total int)) error {
+	active, err := database.VectorTable()
+	if err != nil {
+		return err
+	}
+	shadow := shadowTable(active)
+
+	// Files stored before chunking existed only have their content
+	if err := chunkUnchunkedFiles(); err != nil {
+		return err
+	}
+
+	// Start from scratch in case a previous reindex was interrupted
+	if err := database.DropVectorTable(shadow); err != nil {
+		return fmt.Errorf("failed to drop stale shadow table %s: %w", shadow, err)
+	}
+	if err := database.CreateVectorTable(shadow, provider.Dimensions()); err != nil {
+		return err
+	}
+
+	lastID, err := embedStoredChunks(ctx, provider, shadow, 0, progress)
+	if err != nil {
+		return err
+	}
+
+	// The daemon keeps indexing into the active table meanwhile; re-indexed
+	// files get new, increasing chunk IDs, so pick up everything added since.
+	// The swap only succeeds if nothing was added after the last pass.
+	var previous string
+	for pass := 0; ; pass++ {
+		previous, err = database.SwapVectorTable(shadow, provider.EmbeddingModel(), provider.Dimensions(), lastID)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, database.ErrVectorTableBehind) {
+			return err
+		}
+		if pass == reindexCatchUpPasses {
+			return fmt.Errorf("files kept changing during %d catch-up passes, the previous vectors stay active; run `lamina reindex` again once indexing settles", reindexCatchUpPasses)
+		}
+
+		if lastID, err = embedStoredChunks(ctx, provider, shadow, lastID, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := database.DropVectorTable(previous); err != nil {
+		return fmt.Errorf("failed to drop previous vector table %s: %w", previous, err)
+	}
+	return nil
+}
+
+// embedStoredChunks embeds every chunk with an ID above afterID into table and
+// returns the highest ID embedded.
+func embedStoredChunks(ctx context.Context, provider ai.Provider, table string, afterID uint, progress func(done, total int)) (uint, error) {
+	batchSize := max(config.GetEmbedBatchSize(), 1)
+
+	var total int64
+	if err := database.Store.Model(&database.Chunk{}).Where("id > ?", afterID).Count(&total).Error; err != nil {
+		return afterID, fmt.Errorf("failed to count chunks: %w", err)
+	}
+
+	done := 0
+	lastID := afterID
+	for {
+		var chunks []database.Chunk
+		err := database.Store.
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(batchSize).
+			Find(&chunks).Error
+		if err != nil {
+			return lastID, fmt.Errorf("failed to load chunks: %w", err)
+		}
+		if len(chunks) == 0 {
+			return lastID, nil
+		}
+
+		hashes := make([]string, len(chunks))
+		contents := make([]string, len(chunks))
+		for idx, chunk := range chunks {
+			hashes[idx] = chunk.ContentHash
+			contents[idx] = chunk.Text
+		}
+
+		embeddings, err := embedWithCache(ctx, provider, hashes, contents, batchSize)
+		if err != nil {
+			return lastID, err
+		}
+
+		for idx, chunk := range chunks {
+			vectorBlob, err := database.EncodeVector(embeddings[idx])
+			if err != nil {
+				return lastID, err
+			}
+			if err := database.UpsertVector(table, chunk.ID, vectorBlob); err != nil {
+				return lastID, fmt.Errorf("failed to store vector for chunk %d: %w", chunk.ID, err)
+			}
+		}
+
+		lastID = chunks[len(chunks)-1].ID
+		done += len(chunks)
+		if progress != nil {
+			progress(done, int(total))
+		}
+	}
+}
+
+// chunkUnchunkedFiles splits the stored content of files without chunks.
+func chunkUnchunkedFiles() error {
+	var files []database.File
+	err := database.Store.Where("id NOT IN (SELECT DISTINCT file_id FROM chunks)").Find(&files).Error
+	if err != nil {
+		return fmt.Errorf("failed to load unchunked files: %w", err)
+	}
+
+	for _, file := range files {
+		chunks := chunkContent(file.Content, config.GetChunkSize(), config.GetChunkOverlap())
+		for idx := range chunks {
+			chunks[idx].FileID = file.ID
+		}
+		if len(chunks) == 0 {
+			continue
+		}
+		if err := database.Store.Create(&chunks).Error; err != nil {
+			return fmt.Errorf("failed to chunk %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// shadowTable alternates between two table names so the active one is never touched.
+func shadowTable(active string) string {
+	if active == database.DefaultVectorTable {
+		return database.DefaultVectorTable + "_next"
+	}
+	return database.DefaultVectorTable
+}
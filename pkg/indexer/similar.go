@@ -0,0 +1,41 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"lamina/pkg/ai"
+	"lamina/pkg/config"
+	"lamina/pkg/database"
+)
+
+// EmbedFile embeds a file that isn't in the index the way the indexer would,
+// chunk by chunk, and returns the mean of its chunk vectors. Nothing is stored
+// apart from the chunk vectors in the embedding cache.
+func EmbedFile(ctx context.Context, provider ai.Provider, filePath string) ([]float32, error) {
+	if err := database.CheckEmbeddingModel(provider.EmbeddingModel(), provider.Dimensions()); err != nil {
+		return nil, err
+	}
+
+	var i Indexer
+	content, err := i.getFileContent(filePath)
+	if err != nil {
+		return nil, err
+	}
+	chunks := chunkContent(string(content), config.GetChunkSize(), config.GetChunkOverlap())
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no text could be extracted from %s", filePath)
+	}
+
+	hashes := make([]string, len(chunks))
+	contents := make([]string, len(chunks))
+	for idx, chunk := range chunks {
+		hashes[idx] = chunk.ContentHash
+		contents[idx] = chunk.Text
+	}
+
+	vectors, err := embedWithCache(ctx, provider, hashes, contents, max(config.GetEmbedBatchSize(), 1))
+	if err != nil {
+		return nil, err
+	}
+	return database.MeanVector(vectors), nil
+}
@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFiles are read in every directory below the watch paths, with the
+// gitignore syntax. Rules of .laminaignore come after those of .gitignore, so
+// they win where both match.
+var IgnoreFiles = []string{".gitignore", ".laminaignore"}
+
+// IsIgnoreFile reports whether path is one of the IgnoreFiles.
+func IsIgnoreFile(path string) bool {
+	name := filepath.Base(path)
+	for _, ignoreFile := range IgnoreFiles {
+		if name == ignoreFile {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreRule is a pattern line of an ignore file.
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	// negated rules (!pattern) include what earlier rules excluded
+	negated bool
+	// dirOnly rules (pattern/) only match directories
+	dirOnly bool
+}
+
+// readIgnoreRules reads the rules of the IgnoreFiles in dir, in order.
+func readIgnoreRules(dir string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, name := range IgnoreFiles {
+		path := filepath.Join(dir, name)
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			rule, ok, err := parseIgnoreRule(scanner.Text())
+			if err != nil {
+				fmt.Printf("⚠️ Skipping %s:%d: %v\n", path, line, err)
+				continue
+			}
+			if ok {
+				rules = append(rules, rule)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// parseIgnoreRule parses a line of an ignore file, reporting false for blank
+// lines and comments.
+func parseIgnoreRule(line string) (ignoreRule, bool, error) {
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are dropped unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false, nil
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negated = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false, nil
+	}
+
+	// A slash anywhere but at the end anchors the pattern to the ignore
+	// file's directory, otherwise it matches a name at any depth
+	prefix := "(^|/)"
+	if strings.Contains(line, "/") {
+		prefix = "^"
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	expr, err := globExpr(line)
+	if err != nil {
+		return ignoreRule{}, false, err
+	}
+	rule.pattern, err = regexp.Compile(prefix + expr + "$")
+	if err != nil {
+		return ignoreRule{}, false, err
+	}
+	return rule, true, nil
+}
+
+// ignoreVerdict applies rules to path, relative to their ignore file's
+// directory, returning whether the last matching rule excludes or includes
+// it, and whether any matched.
+func ignoreVerdict(rules []ignoreRule, relative string, isDir bool) (excluded, matched bool) {
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.MatchString(relative) {
+			excluded, matched = !rule.negated, true
+		}
+	}
+	return excluded, matched
+}
@@ -0,0 +1,315 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"lamina/pkg/config"
+)
+
+// Policy decides which paths below the watch paths are indexed. The initial
+// walk and the watcher share one, so both see the same files.
+type Policy struct {
+	// roots are the watch paths, ignore files are read at and below them
+	roots []string
+	// filetypes are matched against the full path of files, any may match
+	filetypes []*regexp.Regexp
+	// excludes are the ignore patterns compiled from globs, matched against
+	// paths relative to their watch path, absoluteExcludes against full paths
+	excludes         []*regexp.Regexp
+	absoluteExcludes []*regexp.Regexp
+	// ignorePaths are absolute paths left out with everything below them
+	ignorePaths []string
+	// fingerprint identifies the settings the policy was built from
+	fingerprint string
+
+	// ignoreRules caches the rules of the ignore files by directory, until Reload
+	mu          sync.Mutex
+	ignoreRules map[string][]ignoreRule
+}
+
+// NewPolicy builds a Policy for the watch paths roots from filetypes regular
+// expressions, ignore pattern globs and absolute ignore paths.
+//
+// A glob without a slash matches a file or directory of that name anywhere
+// below the watch paths, like *.log or node_modules. One with a slash matches
+// a path below the watch paths at any depth, like docs/*.md, unless it starts
+// with / or ~/ and is matched against the full path. Folders above the watch
+// paths never match relative globs. In all of them, * and ? don't cross
+// directories, ** spans any number of them, and a matching directory excludes
+// everything below it.
+//
+// On top of that the IgnoreFiles in every directory below roots exclude paths
+// as git would.
+func NewPolicy(roots, filetypes, ignorePatterns, ignorePaths []string) (*Policy, error) {
+	policy := &Policy{ignoreRules: map[string][]ignoreRule{}}
+
+	// Each list ends with an empty line, so no element can pass for another
+	hash := sha256.New()
+	for _, list := range [][]string{roots, filetypes, ignorePatterns, ignorePaths} {
+		for _, value := range list {
+			fmt.Fprintf(hash, "%q\n", value)
+		}
+		fmt.Fprintln(hash)
+	}
+	policy.fingerprint = fmt.Sprintf("%x", hash.Sum(nil))
+
+	for _, root := range roots {
+		policy.roots = append(policy.roots, filepath.Clean(root))
+	}
+
+	for _, filetype := range filetypes {
+		pattern, err := regexp.Compile(filetype)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filetypes pattern %q: %w", filetype, err)
+		}
+		policy.filetypes = append(policy.filetypes, pattern)
+	}
+
+	for _, glob := range ignorePatterns {
+		pattern, absolute, err := compileGlob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", glob, err)
+		}
+		if absolute {
+			policy.absoluteExcludes = append(policy.absoluteExcludes, pattern)
+		} else {
+			policy.excludes = append(policy.excludes, pattern)
+		}
+	}
+
+	for _, path := range ignorePaths {
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("ignore path %q is not absolute", path)
+		}
+		policy.ignorePaths = append(policy.ignorePaths, filepath.Clean(path))
+	}
+	return policy, nil
+}
+
+// PolicyFromConfig builds the Policy of the watch_paths, filetypes,
+// ignore_patterns and ignore_paths settings.
+func PolicyFromConfig() (*Policy, error) {
+	return NewPolicy(config.GetWatchPaths(), config.GetFiletypes(), config.GetIgnorePatterns(), config.GetIgnorePaths())
+}
+
+// Fingerprint identifies the settings the policy was built from. It changes
+// when they do, but not with the ignore files, which the watcher follows.
+func (p *Policy) Fingerprint() string {
+	return p.fingerprint
+}
+
+// Excluded reports whether path, a directory if isDir, is left out of the
+// index along with everything below it.
+func (p *Policy) Excluded(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	slashed := filepath.ToSlash(path)
+
+	// Editor backups
+	if strings.HasSuffix(slashed, "~") {
+		return true
+	}
+
+	for _, ignored := range p.ignorePaths {
+		ignored = filepath.ToSlash(ignored)
+		if slashed == ignored || strings.HasPrefix(slashed, strings.TrimSuffix(ignored, "/")+"/") {
+			return true
+		}
+	}
+
+	for _, pattern := range p.absoluteExcludes {
+		if pattern.MatchString(slashed) {
+			return true
+		}
+	}
+
+	// Relative patterns only see the path below its watch path, as ignore files do
+	if root := p.rootOf(path); root != "" && root != path {
+		if relative, err := filepath.Rel(root, path); err == nil {
+			relative = filepath.ToSlash(relative)
+			for _, pattern := range p.excludes {
+				if pattern.MatchString(relative) {
+					return true
+				}
+			}
+		}
+	}
+	return p.ignoredByFiles(path, isDir)
+}
+
+// Included reports whether the file at path is indexed.
+func (p *Policy) Included(path string) bool {
+	if p.Excluded(path, false) {
+		return false
+	}
+	if len(p.filetypes) == 0 {
+		return true
+	}
+
+	for _, pattern := range p.filetypes {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether a path found on disk passes the policy, as a
+// directory to descend into or a file to index.
+func (p *Policy) Allows(path string, info os.FileInfo) bool {
+	if info.IsDir() {
+		return !p.Excluded(path, true)
+	}
+	return p.Included(path)
+}
+
+// Reload drops the cached rules of the ignore files in dir, so they're read
+// again the next time they apply.
+func (p *Policy) Reload(dir string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.ignoreRules, filepath.Clean(dir))
+}
+
+// ignoredByFiles applies the ignore files from the watch path holding path
+// down to its directory. As in git, rules in deeper directories override
+// shallower ones, and nothing below an excluded directory comes back.
+func (p *Policy) ignoredByFiles(path string, isDir bool) bool {
+	root := p.rootOf(path)
+	if root == "" || root == path {
+		return false
+	}
+
+	relative, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	names := strings.Split(filepath.ToSlash(relative), "/")
+
+	// dirs have the ignore files that apply to the current path
+	dirs := []string{root}
+	current := root
+	for idx, name := range names {
+		current = filepath.Join(current, name)
+		currentIsDir := isDir || idx < len(names)-1
+
+		excluded := false
+		for _, dir := range dirs {
+			below, err := filepath.Rel(dir, current)
+			if err != nil {
+				continue
+			}
+			if verdict, matched := ignoreVerdict(p.rulesOf(dir), filepath.ToSlash(below), currentIsDir); matched {
+				excluded = verdict
+			}
+		}
+		if excluded {
+			return true
+		}
+		dirs = append(dirs, current)
+	}
+	return false
+}
+
+// rootOf returns the innermost watch path holding path, "" if none does.
+func (p *Policy) rootOf(path string) string {
+	best := ""
+	for _, root := range p.roots {
+		if (path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
+
+// rulesOf returns the rules of the ignore files in dir, reading them once.
+func (p *Policy) rulesOf(dir string) []ignoreRule {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if rules, ok := p.ignoreRules[dir]; ok {
+		return rules
+	}
+	rules, err := readIgnoreRules(dir)
+	if err != nil {
+		fmt.Printf("⚠️ Could not read ignore files in %s: %v\n", dir, err)
+	}
+	p.ignoreRules[dir] = rules
+	return rules
+}
+
+// compileGlob turns an ignore pattern into a regular expression over slash
+// separated paths that also matches everything below a match. It reports
+// whether the pattern is absolute, to match full paths, or else relative to a
+// watch path.
+func compileGlob(glob string) (*regexp.Regexp, bool, error) {
+	glob = filepath.ToSlash(glob)
+	if strings.HasPrefix(glob, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, false, err
+		}
+		glob = filepath.ToSlash(home) + glob[1:]
+	}
+	glob = strings.TrimSuffix(glob, "/")
+	if glob == "" {
+		return nil, false, fmt.Errorf("empty pattern")
+	}
+
+	// Relative patterns match at any depth
+	absolute := strings.HasPrefix(glob, "/")
+	prefix := "(^|/)"
+	if absolute {
+		prefix = "^"
+	}
+
+	expr, err := globExpr(glob)
+	if err != nil {
+		return nil, false, err
+	}
+	pattern, err := regexp.Compile(prefix + expr + "(/|$)")
+	return pattern, absolute, err
+}
+
+// globExpr translates a slash separated glob into an unanchored regular
+// expression. * and ? stay within a directory, ** spans directories and a
+// backslash escapes the next character.
+func globExpr(glob string) (string, error) {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String(), nil
+}
@@ -0,0 +1,33 @@
+package watcher
+
+import "testing"
+
+func TestPolicyExcludedGlobs(t *testing.T) {
+	// The watch path sits below folders named like relative patterns
+	root := "/home/user/tmp/docs/notes"
+	policy, err := NewPolicy([]string{root}, nil, []string{"tmp", "docs/*.md", "*.log", "build/", root + "/private"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{root, false},
+		{root + "/todo.md", false},
+		{root + "/tmp/scratch.md", true},
+		{root + "/docs/guide.md", true},
+		{root + "/project/docs/guide.md", true},
+		{root + "/docs/guide.txt", false},
+		{root + "/server.log", true},
+		{root + "/build/out.txt", true},
+		{root + "/private/keys.txt", true},
+		{root + "/public/keys.txt", false},
+	}
+	for _, test := range tests {
+		if excluded := policy.Excluded(test.path, false); excluded != test.excluded {
+			t.Errorf("Excluded(%s) = %v, want %v", test.path, excluded, test.excluded)
+		}
+	}
+}
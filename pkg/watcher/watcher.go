@@ -6,32 +6,70 @@ import (
 	"lamina/pkg/config"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+// Op is the kind of change an Event reports.
+type Op int
+
+const (
+	// OpWrite means the path was created or modified.
+	OpWrite Op = iota
+	// OpRemove means the path, possibly a directory, no longer exists.
+	OpRemove
+	// OpRename means the path, possibly a directory, was moved from OldPath.
+	OpRename
+	// OpRules means the ignore files of the directory at path changed, so
+	// what's indexed below it may have too.
+	OpRules
+)
+
+// renameWindow is how long a rename waits for the create of its new path.
+// Without one it moved out of sight and is reported as removed.
+const renameWindow = 250 * time.Millisecond
+
+func (op Op) String() string {
+	switch op {
+	case OpWrite:
+		return "write"
+	case OpRemove:
+		return "remove"
+	case OpRename:
+		return "rename"
+	case OpRules:
+		return "rules"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// Event is a change to a file or directory below the watched paths.
+type Event struct {
+	Path string
+	Op   Op
+	// OldPath is where a renamed path was before, set for OpRename
+	OldPath string
+}
+
 // FileWatcher monitors directories for changes.
 type FileWatcher struct {
-	watcher        *fsnotify.Watcher
-	events         chan string
-	ignorePatterns []string
+	watcher *fsnotify.Watcher
+	events  chan Event
+	policy  *Policy
 }
 
-func NewFileWatcher() (*FileWatcher, error) {
-
+// NewFileWatcher creates a FileWatcher reporting the changes policy allows.
+func NewFileWatcher(policy *Policy) (*FileWatcher, error) {
 	watcher, err := fsnotify.NewWatcher()
-
 	if err != nil {
 		return nil, fmt.Errorf("failed to create watcher: %w", err)
 	}
 
-	ignorePatterns := config.GetIgnorePatterns()
-
 	return &FileWatcher{
-		watcher:        watcher,
-		events:         make(chan string, 100),
-		ignorePatterns: ignorePatterns,
+		watcher: watcher,
+		events:  make(chan Event, 100),
+		policy:  policy,
 	}, nil
 }
 
@@ -56,10 +94,8 @@ func (fw *FileWatcher) addPath(path string) error {
 			return err
 		}
 		if info.IsDir() {
-			for _, pattern := range fw.ignorePatterns {
-				if matched, _ := filepath.Match(pattern, info.Name()); matched {
-					return filepath.SkipDir
-				}
+			if fw.policy.Excluded(p, true) {
+				return filepath.SkipDir
 			}
 			return fw.watcher.Add(p)
 		}
@@ -67,27 +103,84 @@ func (fw *FileWatcher) addPath(path string) error {
 	})
 }
 
-func (fw *FileWatcher) Events() <-chan string {
+// Events returns the changes below the watched paths.
+func (fw *FileWatcher) Events() <-chan Event {
 	return fw.events
 }
 
-// watchEvents processes filesystem events.
+// watchEvents processes filesystem events. fsnotify reports a rename as a
+// Rename of the old path followed by a Create of the new one, which are paired
+// into a single OpRename.
 func (fw *FileWatcher) watchEvents(ctx context.Context) {
 	defer fw.watcher.Close()
+
+	// renamed awaits its create, moved and movedTo are the last pair
+	var renamed, moved, movedTo string
+	expired := time.NewTimer(renameWindow)
+	expired.Stop()
+	// A rename without a create moved out of the watched paths
+	flushRename := func() {
+		if renamed != "" {
+			expired.Stop()
+			fw.events <- Event{Path: renamed, Op: OpRemove}
+			renamed = ""
+		}
+	}
+
 	for {
 		select {
 		case event, ok := <-fw.watcher.Events:
 			if !ok {
 				return
 			}
+			if IsIgnoreFile(event.Name) {
+				fw.reloadRules(filepath.Dir(event.Name))
+				continue
+			}
 			if fw.shouldIgnore(event.Name) {
 				continue
 			}
-			if event.Op&fsnotify.Create == fsnotify.Create ||
-				event.Op&fsnotify.Write == fsnotify.Write ||
-				event.Op&fsnotify.Remove == fsnotify.Remove {
-				fw.events <- event.Name
+			if event.Op&fsnotify.Rename != fsnotify.Rename {
+				moved = ""
+			}
+			switch {
+			case event.Op&fsnotify.Remove == fsnotify.Remove:
+				fw.events <- Event{Path: event.Name, Op: OpRemove}
+			case event.Op&fsnotify.Rename == fsnotify.Rename:
+				if moved != "" && (event.Name == moved || event.Name == movedTo) {
+					// A moved directory reports its own move after its parent
+					// did, under either name depending on whether it was
+					// watched again yet. fsnotify drops its watch, so restore it
+					if err := fw.addPath(movedTo); err != nil {
+						fmt.Printf("⚠️ Could not watch %s: %v\n", movedTo, err)
+					}
+					continue
+				}
+				if event.Name == renamed {
+					continue
+				}
+				flushRename()
+				renamed = event.Name
+				expired.Reset(renameWindow)
+			case event.Op&fsnotify.Create == fsnotify.Create:
+				// Watch directories created or moved in, their files are indexed by the receiver
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := fw.addPath(event.Name); err != nil {
+						fmt.Printf("⚠️ Could not watch %s: %v\n", event.Name, err)
+					}
+				}
+				if renamed != "" {
+					expired.Stop()
+					fw.events <- Event{Path: event.Name, Op: OpRename, OldPath: renamed}
+					moved, movedTo, renamed = renamed, event.Name, ""
+					continue
+				}
+				fw.events <- Event{Path: event.Name, Op: OpWrite}
+			case event.Op&fsnotify.Write == fsnotify.Write:
+				fw.events <- Event{Path: event.Name, Op: OpWrite}
 			}
+		case <-expired.C:
+			flushRename()
 		case err, ok := <-fw.watcher.Errors:
 			if !ok {
 				return
@@ -99,18 +192,27 @@ func (fw *FileWatcher) watchEvents(ctx context.Context) {
 	}
 }
 
-// shouldIgnore checks if a path matches any ignore patterns.
-func (fw *FileWatcher) shouldIgnore(path string) bool {
-	base := filepath.Base(path)
+// reloadRules picks up changed ignore files in dir. Directories no longer
+// ignored are watched, and the receiver brings the index up to date.
+func (fw *FileWatcher) reloadRules(dir string) {
+	fw.policy.Reload(dir)
+	if _, err := os.Stat(dir); err != nil || fw.policy.Excluded(dir, true) {
+		// Gone with the directory, or doesn't matter
+		return
+	}
 
-	if strings.HasSuffix(base, "~") { // plsssssssss skip the temporary files mehn
-		return true
+	if err := fw.addPath(dir); err != nil {
+		fmt.Printf("⚠️ Could not watch %s: %v\n", dir, err)
 	}
+	fw.events <- Event{Path: dir, Op: OpRules}
+}
 
-	for _, pattern := range fw.ignorePatterns {
-		if matched, _ := filepath.Match(pattern, base); matched {
-			return true
-		}
+// shouldIgnore checks if the policy leaves out path. Paths that are gone
+// can't be told apart as files or directories, so only exclusions apply.
+func (fw *FileWatcher) shouldIgnore(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fw.policy.Excluded(path, false)
 	}
-	return false
+	return !fw.policy.Allows(path, info)
 }